@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/codecrafters-io/grep-starter-go/src/logs"
+	"github.com/codecrafters-io/grep-starter-go/src/matcher"
+	"github.com/spf13/cobra"
+)
+
+var (
+	explainIgnoreCase bool
+	explainMultiline  bool
+	explainDotAll     bool
+	explainProgram    bool
+)
+
+var explainCmd = &cobra.Command{
+	Use:   "explain <pattern>",
+	Short: "Show how the built-in engine parses a pattern",
+	Long: "Print the syntax tree the built-in engine parses a pattern into, showing its groups, " +
+		"quantifiers, classes and anchors, optionally followed by the compiled program.",
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		re, err := matcher.CompileWithOptions(args[0], matcher.CompileOptions{
+			CaseInsensitive: explainIgnoreCase,
+			Multiline:       explainMultiline,
+			DotAll:          explainDotAll,
+		})
+
+		if err != nil {
+			logs.Fatal("invalid pattern: %s\n", err)
+		}
+
+		fmt.Print(re.Syntax().Tree())
+
+		if explainProgram {
+			fmt.Println()
+			fmt.Print(re.Prog().String())
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(explainCmd)
+	explainCmd.Flags().BoolVarP(&explainIgnoreCase, "ignore-case", "i", false, "Parse the pattern as if it started with (?i)")
+	explainCmd.Flags().BoolVarP(&explainMultiline, "multiline", "m", false, "Parse the pattern as if it started with (?m)")
+	explainCmd.Flags().BoolVarP(&explainDotAll, "dot-all", "s", false, "Parse the pattern as if it started with (?s)")
+	explainCmd.Flags().BoolVarP(&explainProgram, "program", "P", false, "Also print the compiled program, one instruction per line")
+}
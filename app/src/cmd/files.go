@@ -2,11 +2,16 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/codecrafters-io/grep-starter-go/src/file"
+	"github.com/codecrafters-io/grep-starter-go/src/fileutils"
 	"github.com/codecrafters-io/grep-starter-go/src/logs"
+	"github.com/codecrafters-io/grep-starter-go/src/matcher"
 	"github.com/codecrafters-io/grep-starter-go/src/table"
+	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
@@ -25,8 +30,17 @@ var (
 	minSize        int64
 	modifiedAfter  string
 	modifiedBefore string
+	engineName     string
+	maxSteps       int
+	patternsFile   string
+	globSyntax     bool
+	regexSyntax    bool
+	fuzzySyntax    bool
 )
 
+// fuzzyHighlight colors the characters of a file name a fuzzy query matched.
+var fuzzyHighlight = color.New(color.FgGreen, color.Bold).SprintFunc()
+
 func parseTime(timeStr string) (time.Time, error) {
 	t, err := time.Parse(time.DateOnly, timeStr)
 	if err != nil {
@@ -39,7 +53,7 @@ var filesCmd = &cobra.Command{
 	Use:   "ls",
 	Short: "Search for files in a directory",
 	Run: func(cmd *cobra.Command, args []string) {
-		if len(args) == 0 {
+		if len(args) == 0 && patternsFile == "" {
 			logs.Fatal("No pattern provided to search for do something like 'gep ls -p . -r \"*.go\"'")
 		}
 
@@ -57,11 +71,30 @@ var filesCmd = &cobra.Command{
 			logs.Fatal(err.Error())
 		}
 
-		pattern := args[0]
+		engine, err := matcher.NewEngine(engineName)
+		if err != nil {
+			logs.Fatal(err.Error())
+		}
+
+		if globSyntax && regexSyntax {
+			logs.Fatal("--glob and --regex cannot be used together")
+		}
+
+		if fuzzySyntax && (globSyntax || regexSyntax) {
+			logs.Fatal("--fuzzy cannot be used with --glob or --regex")
+		}
+
+		// Pattern sets are always made of regular expressions, which --regex only confirms.
+		if patternsFile != "" && (globSyntax || fuzzySyntax) {
+			logs.Fatal("--patterns-file cannot be used with --glob or --fuzzy")
+		}
+
 		options := file.SearchOptions{
 			Recursive: recursive,
 			Invert:    invert,
 			MaxDepth:  depth,
+			Engine:    engine,
+			MaxSteps:  maxSteps,
 			FileFilter: file.SearchWithFileProperty{
 				CaseSensitive:  caseSensitive,
 				Hidden:         hidden,
@@ -72,13 +105,23 @@ var filesCmd = &cobra.Command{
 			},
 		}
 
+		if patternsFile != "" {
+			searchPatternSet(args, options)
+			return
+		}
+
+		pattern := args[0]
+		options.Fuzzy = fuzzySyntax
+		options.Glob = !fuzzySyntax && (globSyntax || !regexSyntax && matcher.LooksLikeGlob(pattern))
+
 		files, err := file.SearchWithPattern(searchPath, pattern, options)
+		fatalOnSearchError(err)
 
-		if err != nil {
-			logs.Fatal(err.Error())
+		if options.Fuzzy && !options.Invert {
+			highlightFuzzyMatches(files, pattern)
+		} else {
+			files = file.SortByDepth(files)
 		}
-
-		files = file.SortByDepth(files)
 		if err := table.PrintTable(files, table.Options{
 			Centered: true,
 			Border:   true,
@@ -88,6 +131,114 @@ var filesCmd = &cobra.Command{
 	},
 }
 
+// highlightFuzzyMatches colors the characters the fuzzy query matched in the name of
+// each file, or in its path if the query is matched against paths, at the positions
+// the search recorded in the file's Fuzzy field.
+func highlightFuzzyMatches(files []file.File, pattern string) {
+	matchPath := file.FuzzyMatchesPath(pattern)
+
+	for i, f := range files {
+		if f.Fuzzy == nil {
+			continue
+		}
+
+		text := file.FuzzyText(f, matchPath)
+		var b strings.Builder
+		last := 0
+		for _, pos := range f.Fuzzy.Positions {
+			_, width := utf8.DecodeRuneInString(text[pos:])
+			b.WriteString(text[last:pos])
+			b.WriteString(fuzzyHighlight(text[pos : pos+width]))
+			last = pos + width
+		}
+		b.WriteString(text[last:])
+
+		if matchPath {
+			files[i].Path = b.String()
+		} else {
+			files[i].Name = b.String()
+		}
+	}
+}
+
+// setMatchRow is a row of the table printed for a pattern set search.
+type setMatchRow struct {
+	Name     string
+	Size     string
+	ModTime  string
+	Perms    string
+	Path     string
+	Patterns string
+}
+
+// searchPatternSet searches for files matching any of the patterns read from the
+// patterns file, plus any given as arguments, and prints them along with the patterns
+// each one matched.
+func searchPatternSet(args []string, options file.SearchOptions) {
+	patterns, err := readPatterns(patternsFile)
+	if err != nil {
+		logs.Fatal(err.Error())
+	}
+	patterns = append(patterns, args...)
+
+	matches, err := file.SearchWithPatternSet(searchPath, patterns, options)
+	fatalOnSearchError(err)
+
+	matches = file.SortSetMatchesByDepth(matches)
+	rows := make([]setMatchRow, len(matches))
+	for i, m := range matches {
+		matched := make([]string, len(m.Patterns))
+		for j, id := range m.Patterns {
+			matched[j] = patterns[id]
+		}
+		rows[i] = setMatchRow{
+			Name:     m.Name,
+			Size:     m.Size,
+			ModTime:  m.ModTime,
+			Perms:    m.Perms,
+			Path:     m.Path,
+			Patterns: strings.Join(matched, ", "),
+		}
+	}
+
+	if err := table.PrintTable(rows, table.Options{
+		Centered: true,
+		Border:   true,
+	}); err != nil {
+		logs.Fatal(err.Error())
+	}
+}
+
+// readPatterns returns the patterns in a file, one per line. Blank lines are skipped.
+func readPatterns(path string) ([]string, error) {
+	content, err := fileutils.ReadFileContent(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var patterns []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSuffix(line, "\r")
+		if line != "" {
+			patterns = append(patterns, line)
+		}
+	}
+
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("no patterns in %s", path)
+	}
+	return patterns, nil
+}
+
+// fatalOnSearchError exits with a message if a search failed. The error is printed as
+// the search wrapped it, so a malformed pattern is reported as an invalid pattern, glob
+// or pattern set member, followed by where it went wrong.
+func fatalOnSearchError(err error) {
+	if err != nil {
+		logs.Fatal("%s\n", err)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(filesCmd)
 	filesCmd.Flags().StringVarP(&searchPath, "path", "p", ".", "The path to search for files")
@@ -100,4 +251,10 @@ func init() {
 	filesCmd.Flags().Int64VarP(&minSize, "min-size", "m", 0, "Minimum file size to search for")
 	filesCmd.Flags().StringVarP(&modifiedAfter, "modified-after", "a", "", "Search for files modified after a certain date")
 	filesCmd.Flags().StringVarP(&modifiedBefore, "modified-before", "b", "", "Search for files modified before a certain date")
+	filesCmd.Flags().StringVarP(&engineName, "engine", "e", matcher.EngineBuiltin, "Pattern engine to use: builtin, re2 (Go regexp) or pcre (regexp2)")
+	filesCmd.Flags().BoolVar(&globSyntax, "glob", false, "Treat the pattern as a glob such as \"*.go\" or \"src/**/*.{yml,yaml}\" (default when it looks like one)")
+	filesCmd.Flags().BoolVar(&regexSyntax, "regex", false, "Treat the pattern as a regular expression even if it looks like a glob")
+	filesCmd.Flags().BoolVar(&fuzzySyntax, "fuzzy", false, "Treat the pattern as a fuzzy query such as \"flsgo\" for files.go, ranking files by how well they match")
+	filesCmd.Flags().StringVarP(&patternsFile, "patterns-file", "f", "", "Read regular expressions from a file, one per line, and match them all in one pass (builtin engine only)")
+	filesCmd.Flags().IntVar(&maxSteps, "max-steps", 1_000_000, "Steps the builtin engine may take to match a single file name before giving up, or 100ns per step for the pcre engine (0 means unlimited)")
 }
@@ -1,8 +1,11 @@
 package file
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/fs"
+	"math"
 	"os"
 	"path/filepath"
 	"sort"
@@ -19,12 +22,17 @@ type File struct {
 	Perms   string
 	Path    string
 	AbsPath string
+	Fuzzy   *matcher.FuzzyMatch // how a fuzzy search's query matched FuzzyText of the file, nil otherwise
 }
 
 type SearchOptions struct {
 	Recursive  bool
 	Invert     bool
 	MaxDepth   int
+	Engine     matcher.Engine // engine used to compile the pattern; nil means the built-in one
+	Glob       bool           // the pattern is a glob for matcher.CompileGlob rather than one for Engine
+	Fuzzy      bool           // the pattern is a query for matcher.CompileFuzzy rather than one for Engine
+	MaxSteps   int            // steps a single file name match may take before the search fails; 0 means no limit
 	FileFilter SearchWithFileProperty
 }
 
@@ -51,43 +59,218 @@ func FromInfo(basePath, currentFilePath string, info fs.FileInfo) File {
 }
 
 // SearchWithPattern searches for files matching the pattern in the given directory path
-// and returns a slice of matching File structs.
+// and returns a slice of matching File structs. The pattern is compiled once up front
+// with the configured engine, and an error wrapping the engine's error (a
+// *matcher.SyntaxError for the built-in engine) is returned if it is malformed.
+//
+// With Glob set the pattern is compiled as a glob instead, whatever the engine. A glob
+// is matched against the whole file name, or against the slash-separated path below
+// searchPath if it contains a '/', so that src/**/*.go works as expected.
+//
+// With Fuzzy set the pattern is a fuzzy query instead, matched the same way, and the
+// files are returned ranked by score, best first, rather than in walk order, with the
+// score and matched positions of each in its Fuzzy field.
+//
+// If matching a file name runs out of MaxSteps, the search stops with an error wrapping
+// matcher.ErrMatchBudgetExceeded, since whether the file matches is unknown.
 func SearchWithPattern(searchPath, pattern string, options SearchOptions) ([]File, error) {
 	if !options.Recursive {
 		options.MaxDepth = 1
 	}
-	return searchFiles(searchPath, pattern, options)
+
+	engine := options.Engine
+	if engine == nil {
+		engine, _ = matcher.NewEngine(matcher.EngineBuiltin)
+	}
+
+	opts := matcher.CompileOptions{
+		CaseInsensitive: !options.FileFilter.CaseSensitive,
+		MaxSteps:        options.MaxSteps,
+	}
+
+	if options.Fuzzy {
+		query := matcher.CompileFuzzy(pattern, opts)
+		matchPath := FuzzyMatchesPath(pattern)
+		files, err := searchFiles(searchPath, plainMatcher{query}, matchPath, options)
+		if err != nil || options.Invert {
+			return files, err
+		}
+		return SortByScore(files, query, matchPath), nil
+	}
+
+	if options.Glob {
+		compiled, err := matcher.CompileGlobWithOptions(pattern, opts)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob: %w", err)
+		}
+		return searchFiles(searchPath, compiled, strings.ContainsRune(pattern, matcher.GlobSeparator), options)
+	}
+
+	compiled, err := engine.CompileWithOptions(pattern, opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return searchFiles(searchPath, patternMatcher(compiled), false, options)
+}
+
+// SetMatch is a file found by SearchWithPatternSet along with the patterns its name matched.
+type SetMatch struct {
+	File
+	Patterns []int // indexes of the matched patterns in the list, in increasing order
+}
+
+// SearchWithPatternSet is like SearchWithPattern but looks for file names matching any
+// of the patterns, which are compiled once into a matcher.Set and matched in a single
+// pass. Only the built-in engine supports pattern sets. With Invert set it finds the
+// files matching none of the patterns. As with SearchWithPattern, the search fails if
+// matching a file name runs out of MaxSteps.
+func SearchWithPatternSet(searchPath string, patterns []string, options SearchOptions) ([]SetMatch, error) {
+	if !options.Recursive {
+		options.MaxDepth = 1
+	}
+
+	if options.Engine != nil && options.Engine.Name() != matcher.EngineBuiltin {
+		return nil, fmt.Errorf("pattern sets are not supported by the %s engine", options.Engine.Name())
+	}
+
+	opts := matcher.CompileOptions{
+		CaseInsensitive: !options.FileFilter.CaseSensitive,
+		MaxSteps:        options.MaxSteps,
+	}
+	set, err := matcher.CompileSetWithOptions(patterns, opts)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+
+	files, err := searchFiles(searchPath, set, false, options)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]SetMatch, len(files))
+	for i, f := range files {
+		ids, err := set.MatchesContext(context.Background(), []byte(f.Name))
+		if err != nil {
+			return nil, fmt.Errorf("matching %s: %w", f.Path, err)
+		}
+		matches[i] = SetMatch{File: f, Patterns: ids}
+	}
+	return matches, nil
 }
 
 // SortByDepth sorts the files by their depth in the directory tree.
 // If two files have the same depth, they are sorted by their path.
 func SortByDepth(files []File) []File {
 	sort.Slice(files, func(i, j int) bool {
-		di, _ := calcDepth(files[i].Path)
-		dj, _ := calcDepth(files[j].Path)
+		return shallower(files[i].Path, files[j].Path)
+	})
+	return files
+}
 
-		if di == dj {
-			return files[i].Path < files[j].Path
+// FuzzyMatchesPath reports whether a fuzzy query is matched against the slash-separated
+// path of a file rather than its name, which it is if it contains a '/'.
+func FuzzyMatchesPath(query string) bool {
+	return strings.ContainsRune(query, matcher.GlobSeparator)
+}
+
+// FuzzyText returns the text of a file a fuzzy query is matched against: its
+// slash-separated path if matchPath is set, its name otherwise.
+func FuzzyText(f File, matchPath bool) string {
+	if matchPath {
+		return filepath.ToSlash(f.Path)
+	}
+	return f.Name
+}
+
+// SortByScore sorts the files by how well their name, or path if matchPath is set,
+// matches the fuzzy query, best first, and records how each matched in its Fuzzy field.
+// Files with the same score are sorted the way SortByDepth sorts them, and files the
+// query does not match come last, with a nil Fuzzy field.
+func SortByScore(files []File, query *matcher.Fuzzy, matchPath bool) []File {
+	for i, f := range files {
+		files[i].Fuzzy = nil
+		if m, ok := query.Find([]byte(FuzzyText(f, matchPath))); ok {
+			files[i].Fuzzy = &m
 		}
+	}
 
-		return di < dj
+	score := func(f File) int {
+		if f.Fuzzy == nil {
+			return math.MinInt
+		}
+		return f.Fuzzy.Score
+	}
+	sort.Slice(files, func(i, j int) bool {
+		si, sj := score(files[i]), score(files[j])
+		if si == sj {
+			return shallower(files[i].Path, files[j].Path)
+		}
+		return si > sj
 	})
 	return files
 }
 
+// SortSetMatchesByDepth sorts the results of SearchWithPatternSet the way SortByDepth
+// sorts files.
+func SortSetMatchesByDepth(matches []SetMatch) []SetMatch {
+	sort.Slice(matches, func(i, j int) bool {
+		return shallower(matches[i].Path, matches[j].Path)
+	})
+	return matches
+}
+
+// shallower reports whether path a sorts before path b: it is less deep in the
+// directory tree, or equally deep and lexically smaller.
+func shallower(a, b string) bool {
+	da, _ := calcDepth(a)
+	db, _ := calcDepth(b)
+
+	if da == db {
+		return a < b
+	}
+
+	return da < db
+}
+
+// nameMatcher is what searchFiles matches file names with: a matcher.ContextPattern, a
+// matcher.Set, or a plainMatcher wrapping anything that cannot run out of steps.
+type nameMatcher interface {
+	MatchContext(ctx context.Context, name []byte) (bool, error)
+}
+
+// plainMatcher adapts a matcher that never gives up, such as a matcher.Fuzzy or a
+// pattern of the re2 engine, to nameMatcher.
+type plainMatcher struct {
+	matcher interface{ Match(name []byte) bool }
+}
+
+func (m plainMatcher) MatchContext(_ context.Context, name []byte) (bool, error) {
+	return m.matcher.Match(name), nil
+}
+
+// patternMatcher returns the nameMatcher for a compiled pattern, which reports budget
+// exhaustion if its engine can.
+func patternMatcher(p matcher.Pattern) nameMatcher {
+	if cp, ok := p.(matcher.ContextPattern); ok {
+		return cp
+	}
+	return plainMatcher{p}
+}
+
 // searchFiles searches for files matching the pattern in the given directory path
 // and returns a slice of matching File structs.
 //
 // Parameters:
 //   - searchPath: The directory path to search in
-//   - pattern: The pattern to match files against
-//   - filter: A function that determines whether a file matches the pattern
+//   - pattern: The compiled pattern or pattern set to match file names against
+//   - matchPath: Whether to match the slash-separated path below searchPath instead of the name
 //   - options: The search options
 //
 // Returns:
 //   - []File: A slice of matching File structs
-//   - error: An error if something goes wrong
-func searchFiles(searchPath, pattern string, options SearchOptions) ([]File, error) {
+//   - error: An error if something goes wrong, wrapping matcher.ErrMatchBudgetExceeded
+//     if matching a file name ran out of steps
+func searchFiles(searchPath string, pattern nameMatcher, matchPath bool, options SearchOptions) ([]File, error) {
 	var foundFiles []File
 	basePath, err := filepath.Abs(searchPath)
 	if err != nil {
@@ -108,7 +291,20 @@ func searchFiles(searchPath, pattern string, options SearchOptions) ([]File, err
 			return nil
 		}
 
-		if filterFile(d, pattern, options.Invert, options.FileFilter) {
+		name := d.Name()
+		if matchPath {
+			rel, err := filepath.Rel(searchPath, path)
+			if err != nil {
+				return err
+			}
+			name = filepath.ToSlash(rel)
+		}
+
+		keep, err := filterFile(d, name, pattern, options.Invert, options.FileFilter)
+		if err != nil {
+			return fmt.Errorf("matching %s: %w", path, err)
+		}
+		if !keep {
 			return nil
 		}
 
@@ -121,6 +317,9 @@ func searchFiles(searchPath, pattern string, options SearchOptions) ([]File, err
 		return nil
 	})
 
+	if errors.Is(err, matcher.ErrMatchBudgetExceeded) {
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error walking directory: %v", err)
 	}
@@ -129,42 +328,41 @@ func searchFiles(searchPath, pattern string, options SearchOptions) ([]File, err
 }
 
 // filterFile filters a file based on the given options and returns true if the file matches the pattern.
+// The pattern is matched against name, which is the file name or its path.
 // If invert is true, the function returns true if the file does not match the pattern.
-func filterFile(file os.DirEntry, pattern string, invert bool, options SearchWithFileProperty) bool {
+// It returns the pattern's error if the match gave up, as the file can then be kept neither way.
+func filterFile(file os.DirEntry, name string, pattern nameMatcher, invert bool, options SearchWithFileProperty) (bool, error) {
 	info, err := file.Info()
 	if err != nil {
-		return false
+		return false, nil
 	}
 
 	if options.MaxSize > 0 && info.Size() > options.MaxSize {
-		return false
+		return false, nil
 	}
 
 	if options.MinSize > 0 && info.Size() < options.MinSize {
-		return false
+		return false, nil
 	}
 
 	modTime := info.ModTime()
 	if !options.ModifiedBefore.IsZero() && modTime.After(options.ModifiedBefore) {
-		return false
+		return false, nil
 	}
 
 	if !options.ModifiedAfter.IsZero() && modTime.Before(options.ModifiedAfter) {
-		return false
+		return false, nil
 	}
 
-	fileName := file.Name()
-	if !options.Hidden && strings.HasPrefix(fileName, ".") {
-		return false
+	if !options.Hidden && strings.HasPrefix(file.Name(), ".") {
+		return false, nil
 	}
 
-	if !options.CaseSensitive {
-		pattern = strings.ToLower(pattern)
-		fileName = strings.ToLower(fileName)
+	match, err := pattern.MatchContext(context.Background(), []byte(name))
+	if err != nil {
+		return false, err
 	}
-
-	match := matcher.Match([]byte(fileName), pattern)
-	return invert != match
+	return invert != match, nil
 }
 
 // formatSize converts a size in bytes to a human-readable string with appropriate units (B, KB, MB, etc)
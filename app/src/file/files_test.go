@@ -0,0 +1,418 @@
+package file
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/codecrafters-io/grep-starter-go/src/matcher"
+)
+
+// makeTree creates the files, given as slash-separated paths, under a new temporary
+// directory and returns it.
+func makeTree(t *testing.T, paths ...string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	for _, p := range paths {
+		full := filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(p), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+// chdir changes the working directory to dir for the rest of the test, so that it can
+// search the relative path "." and compare the paths it finds.
+func chdir(t *testing.T, dir string) {
+	t.Helper()
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func names(files []File) []string {
+	var got []string
+	for _, f := range files {
+		got = append(got, f.Name)
+	}
+	sort.Strings(got)
+	return got
+}
+
+func TestSearchWithPattern(t *testing.T) {
+	root := makeTree(t, "main.go", "README.md", "src/files.go", "src/files_test.go", ".hidden.go")
+
+	tests := []struct {
+		name    string
+		pattern string
+		options SearchOptions
+		want    []string
+	}{
+		{
+			name:    "matching names only",
+			pattern: "\\.go$",
+			options: SearchOptions{Recursive: true},
+			want:    []string{"files.go", "files_test.go", "main.go"},
+		},
+		{
+			name:    "inverted",
+			pattern: "\\.go$",
+			options: SearchOptions{Recursive: true, Invert: true},
+			want:    []string{"README.md"},
+		},
+		{
+			name:    "case insensitive by default",
+			pattern: "readme",
+			options: SearchOptions{Recursive: true},
+			want:    []string{"README.md"},
+		},
+		{
+			name:    "case sensitive",
+			pattern: "readme",
+			options: SearchOptions{Recursive: true, FileFilter: SearchWithFileProperty{CaseSensitive: true}},
+			want:    nil,
+		},
+		{
+			name:    "hidden files",
+			pattern: "^\\.",
+			options: SearchOptions{Recursive: true, FileFilter: SearchWithFileProperty{Hidden: true}},
+			want:    []string{".hidden.go"},
+		},
+		{
+			name:    "no match",
+			pattern: "nothing",
+			options: SearchOptions{Recursive: true},
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := SearchWithPattern(root, tt.pattern, tt.options)
+			if err != nil {
+				t.Fatalf("SearchWithPattern() error = %v", err)
+			}
+			if got := names(files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SearchWithPattern() names = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchWithPatternInvalid(t *testing.T) {
+	if _, err := SearchWithPattern(t.TempDir(), "(", SearchOptions{}); err == nil {
+		t.Error("SearchWithPattern() error = nil, want an invalid pattern error")
+	}
+}
+
+func TestSearchWithPatternBudget(t *testing.T) {
+	// Whether the long name matches is unknown once the budget runs out, so neither the
+	// search nor its inverse may quietly report it as a non-match.
+	root := makeTree(t, strings.Repeat("a", 40)+"xc", "plain.txt")
+	pattern := "(a|aa)+\\1c"
+
+	for _, invert := range []bool{false, true} {
+		files, err := SearchWithPattern(root, pattern, SearchOptions{Recursive: true, Invert: invert, MaxSteps: 100000})
+		if files != nil || !errors.Is(err, matcher.ErrMatchBudgetExceeded) {
+			t.Errorf("Invert %v: SearchWithPattern() = %v, %v, want nil, ErrMatchBudgetExceeded", invert, names(files), err)
+		}
+	}
+
+	// Names the pattern settles within the budget are still filtered as usual.
+	root = makeTree(t, "aaxc", "plain.txt")
+	files, err := SearchWithPattern(root, pattern, SearchOptions{Recursive: true, Invert: true, MaxSteps: 100000})
+	if err != nil {
+		t.Fatalf("SearchWithPattern() error = %v", err)
+	}
+	if got, want := names(files), []string{"aaxc", "plain.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SearchWithPattern() names = %v, want %v", got, want)
+	}
+}
+
+func TestSearchWithPatternEngines(t *testing.T) {
+	root := makeTree(t, "main.go", "main_test.go", "aa.txt", "ab.txt")
+
+	tests := []struct {
+		engine  string
+		pattern string
+		want    []string
+		wantErr bool
+	}{
+		{engine: matcher.EngineRE2, pattern: "^main.*\\.go$", want: []string{"main.go", "main_test.go"}},
+		{engine: matcher.EngineRE2, pattern: "^(a)\\1", wantErr: true},
+		{engine: matcher.EnginePCRE, pattern: "^(?!.*_test).*\\.go$", want: []string{"main.go"}},
+		{engine: matcher.EnginePCRE, pattern: "^(a)\\1", want: []string{"aa.txt"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.engine+" "+tt.pattern, func(t *testing.T) {
+			engine, err := matcher.NewEngine(tt.engine)
+			if err != nil {
+				t.Fatalf("NewEngine(%q) error = %v", tt.engine, err)
+			}
+
+			files, err := SearchWithPattern(root, tt.pattern, SearchOptions{Recursive: true, Engine: engine})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SearchWithPattern() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := names(files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SearchWithPattern() names = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := matcher.NewEngine("perl"); err == nil {
+		t.Error("NewEngine(\"perl\") error = nil, want an unknown engine error")
+	}
+}
+
+func TestSearchWithPatternSet(t *testing.T) {
+	root := makeTree(t, "main.go", "main_test.go", "README.md", "notes.txt")
+	patterns := []string{"\\.go$", "^main", "test"}
+
+	matches, err := SearchWithPatternSet(root, patterns, SearchOptions{Recursive: true})
+	if err != nil {
+		t.Fatalf("SearchWithPatternSet() error = %v", err)
+	}
+	got := make(map[string][]int)
+	for _, m := range matches {
+		got[m.Name] = m.Patterns
+	}
+	want := map[string][]int{
+		"main.go":      {0, 1},
+		"main_test.go": {0, 1, 2},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SearchWithPatternSet() patterns = %v, want %v", got, want)
+	}
+
+	matches, err = SearchWithPatternSet(root, patterns, SearchOptions{Recursive: true, Invert: true})
+	if err != nil {
+		t.Fatalf("SearchWithPatternSet() inverted error = %v", err)
+	}
+	var inverted []string
+	for _, m := range matches {
+		if m.Patterns != nil {
+			t.Errorf("SearchWithPatternSet() inverted: %s matched patterns %v", m.Name, m.Patterns)
+		}
+		inverted = append(inverted, m.Name)
+	}
+	sort.Strings(inverted)
+	if want := []string{"README.md", "notes.txt"}; !reflect.DeepEqual(inverted, want) {
+		t.Errorf("SearchWithPatternSet() inverted names = %v, want %v", inverted, want)
+	}
+}
+
+func TestSearchWithPatternSetEngines(t *testing.T) {
+	root := makeTree(t, "main.go")
+
+	for _, name := range []string{matcher.EngineRE2, matcher.EnginePCRE} {
+		engine, err := matcher.NewEngine(name)
+		if err != nil {
+			t.Fatalf("NewEngine(%q) error = %v", name, err)
+		}
+
+		matches, err := SearchWithPatternSet(root, []string{"main"}, SearchOptions{Recursive: true, Engine: engine})
+		if matches != nil || err == nil || !strings.Contains(err.Error(), name) {
+			t.Errorf("%s: SearchWithPatternSet() = %v, %v, want an error naming the engine", name, matches, err)
+		}
+	}
+
+	if _, err := SearchWithPatternSet(root, []string{"ok", "bad["}, SearchOptions{Recursive: true}); err == nil {
+		t.Error("SearchWithPatternSet() error = nil, want an invalid pattern error")
+	}
+}
+
+func TestSearchWithPatternGlob(t *testing.T) {
+	root := makeTree(t, "main.go", "README.md", "ci.yml", "src/files.go", "src/cmd/root.go", "docs/guide.md")
+
+	tests := []struct {
+		name    string
+		pattern string
+		options SearchOptions
+		want    []string
+	}{
+		{
+			name:    "extension",
+			pattern: "*.go",
+			options: SearchOptions{Recursive: true, Glob: true},
+			want:    []string{"files.go", "main.go", "root.go"},
+		},
+		{
+			name:    "path below a directory",
+			pattern: "src/**/*.go",
+			options: SearchOptions{Recursive: true, Glob: true},
+			want:    []string{"files.go", "root.go"},
+		},
+		{
+			name:    "alternatives",
+			pattern: "*.{md,yml}",
+			options: SearchOptions{Recursive: true, Glob: true},
+			want:    []string{"README.md", "ci.yml", "guide.md"},
+		},
+		{
+			name:    "inverted",
+			pattern: "*.{go,md}",
+			options: SearchOptions{Recursive: true, Glob: true, Invert: true},
+			want:    []string{"ci.yml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := SearchWithPattern(root, tt.pattern, tt.options)
+			if err != nil {
+				t.Fatalf("SearchWithPattern() error = %v", err)
+			}
+			if got := names(files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SearchWithPattern() names = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSearchWithPatternGlobNonRecursive(t *testing.T) {
+	chdir(t, makeTree(t, "main.go", "src/files.go"))
+
+	files, err := SearchWithPattern(".", "*.go", SearchOptions{Glob: true})
+	if err != nil {
+		t.Fatalf("SearchWithPattern() error = %v", err)
+	}
+	if got, want := names(files), []string{"main.go"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("SearchWithPattern() names = %v, want %v", got, want)
+	}
+}
+
+func TestSearchWithPatternGlobInvalid(t *testing.T) {
+	_, err := SearchWithPattern(t.TempDir(), "*.{go,md", SearchOptions{Glob: true})
+
+	var syntaxErr *matcher.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		t.Fatalf("SearchWithPattern() error = %v, want one wrapping a *matcher.SyntaxError", err)
+	}
+	if !strings.HasPrefix(err.Error(), "invalid glob: ") {
+		t.Errorf("SearchWithPattern() error = %q, want it to start with %q", err, "invalid glob: ")
+	}
+}
+
+func paths(files []File) []string {
+	var got []string
+	for _, f := range files {
+		got = append(got, filepath.ToSlash(f.Path))
+	}
+	return got
+}
+
+func TestSearchWithPatternFuzzy(t *testing.T) {
+	chdir(t, makeTree(t, "files.go", "fileutils_test.go", "src/fuzzy.go", "src/legacy/flags.go", "notes.txt"))
+
+	tests := []struct {
+		name    string
+		pattern string
+		options SearchOptions
+		want    []string
+	}{
+		{
+			name:    "ranked best first",
+			pattern: "flsgo",
+			options: SearchOptions{Recursive: true, Fuzzy: true},
+			want:    []string{"src/legacy/flags.go", "files.go", "fileutils_test.go"},
+		},
+		{
+			name:    "query with a slash matches paths",
+			pattern: "src/fgo",
+			options: SearchOptions{Recursive: true, Fuzzy: true},
+			want:    []string{"src/fuzzy.go", "src/legacy/flags.go"},
+		},
+		{
+			name:    "inverted in walk order",
+			pattern: "flsgo",
+			options: SearchOptions{Recursive: true, Fuzzy: true, Invert: true},
+			want:    []string{"notes.txt", "src/fuzzy.go"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := SearchWithPattern(".", tt.pattern, tt.options)
+			if err != nil {
+				t.Fatalf("SearchWithPattern() error = %v", err)
+			}
+			if got := paths(files); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SearchWithPattern() paths = %v, want %v", got, tt.want)
+			}
+
+			matchPath := FuzzyMatchesPath(tt.pattern)
+			for _, f := range files {
+				if tt.options.Invert {
+					if f.Fuzzy != nil {
+						t.Errorf("%s: Fuzzy = %+v, want nil for an inverted search", f.Path, f.Fuzzy)
+					}
+					continue
+				}
+				if f.Fuzzy == nil || len(f.Fuzzy.Positions) != len([]rune(tt.pattern)) {
+					t.Errorf("%s: Fuzzy = %+v, want a position per query character", f.Path, f.Fuzzy)
+					continue
+				}
+				text := FuzzyText(f, matchPath)
+				for i, pos := range f.Fuzzy.Positions {
+					if text[pos] != tt.pattern[i] {
+						t.Errorf("%s: position %d is %q in %q, want %q", f.Path, pos, text[pos], text, tt.pattern[i])
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSortByScore(t *testing.T) {
+	files := []File{
+		{Name: "readme.md", Path: "readme.md"},
+		{Name: "regexp.go", Path: "src/matcher/regexp.go"},
+		{Name: "regexp.go", Path: "regexp.go"},
+		{Name: "engine.go", Path: "src/matcher/engine.go"},
+		{Name: "rgx.go", Path: "rgx.go"},
+	}
+	query := matcher.CompileFuzzy("rgxgo", matcher.CompileOptions{})
+
+	got := paths(SortByScore(files, query, false))
+	want := []string{"rgx.go", "regexp.go", "src/matcher/regexp.go", "readme.md", "src/matcher/engine.go"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SortByScore() = %v, want %v", got, want)
+	}
+	if files[3].Fuzzy != nil || files[4].Fuzzy != nil {
+		t.Errorf("SortByScore() Fuzzy = %+v, %+v for non-matching files, want nil", files[3].Fuzzy, files[4].Fuzzy)
+	}
+	if files[1].Fuzzy.Score != files[2].Fuzzy.Score {
+		t.Errorf("SortByScore() scores %d and %d for the same name, want them equal", files[1].Fuzzy.Score, files[2].Fuzzy.Score)
+	}
+}
+
+func TestFuzzyMatchesPath(t *testing.T) {
+	tests := map[string]bool{
+		"flsgo":     false,
+		"src/flsgo": true,
+		"/main":     true,
+		"":          false,
+	}
+	for query, want := range tests {
+		if got := FuzzyMatchesPath(query); got != want {
+			t.Errorf("FuzzyMatchesPath(%q) = %v, want %v", query, got, want)
+		}
+	}
+}
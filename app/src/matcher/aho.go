@@ -0,0 +1,118 @@
+package matcher
+
+// acNode is a state of an Aho-Corasick automaton: the longest keyword prefix read so
+// far. Keywords are stored as a trie, and fail points at the state for the longest
+// proper suffix of this one that is also a prefix of some keyword.
+type acNode struct {
+	next map[byte]int32
+	fail int32
+	out  []int // ids of the keywords ending here, including those reached through fail
+}
+
+// ahoCorasick finds every occurrence of a set of keywords in a single pass over a line,
+// taking time linear in the line plus the number of occurrences.
+type ahoCorasick struct {
+	nodes []acNode
+	fold  bool // keywords and lines are compared with ASCII letters lowered
+}
+
+// newAhoCorasick builds an automaton for the keywords, reporting keywords[i] as ids[i].
+//
+// Parameters:
+// - keywords: The non-empty strings to look for.
+// - ids: The id reported for each keyword. Several keywords may share one id.
+// - fold: Whether ASCII letters match regardless of case.
+//
+// Returns:
+// - *ahoCorasick: The automaton, ready for scan.
+func newAhoCorasick(keywords []string, ids []int, fold bool) *ahoCorasick {
+	ac := &ahoCorasick{nodes: []acNode{{}}, fold: fold}
+
+	for i, kw := range keywords {
+		state := int32(0)
+		for j := 0; j < len(kw); j++ {
+			state = ac.child(state, ac.lower(kw[j]))
+		}
+		ac.nodes[state].out = append(ac.nodes[state].out, ids[i])
+	}
+
+	ac.link()
+	return ac
+}
+
+// child returns the trie child of state for b, adding it if it does not exist yet.
+func (ac *ahoCorasick) child(state int32, b byte) int32 {
+	if next, ok := ac.nodes[state].next[b]; ok {
+		return next
+	}
+
+	if ac.nodes[state].next == nil {
+		ac.nodes[state].next = make(map[byte]int32)
+	}
+	ac.nodes = append(ac.nodes, acNode{})
+	next := int32(len(ac.nodes) - 1)
+	ac.nodes[state].next[b] = next
+	return next
+}
+
+// link sets the fail link of every state breadth first, so that the link of a state's
+// parent is always known before its own, and merges in the outputs found through it.
+func (ac *ahoCorasick) link() {
+	queue := make([]int32, 0, len(ac.nodes))
+	for _, next := range ac.nodes[0].next {
+		queue = append(queue, next)
+	}
+
+	for len(queue) > 0 {
+		state := queue[0]
+		queue = queue[1:]
+
+		for b, next := range ac.nodes[state].next {
+			fail := ac.nodes[state].fail
+			for {
+				if target, ok := ac.nodes[fail].next[b]; ok {
+					ac.nodes[next].fail = target
+					break
+				}
+				if fail == 0 {
+					break
+				}
+				fail = ac.nodes[fail].fail
+			}
+
+			ac.nodes[next].out = append(ac.nodes[next].out, ac.nodes[ac.nodes[next].fail].out...)
+			queue = append(queue, next)
+		}
+	}
+}
+
+// scan calls hit with the id of every keyword occurrence in the line, in the order the
+// occurrences end. An id is reported once per occurrence.
+func (ac *ahoCorasick) scan(line []byte, hit func(id int)) {
+	state := int32(0)
+	for _, b := range line {
+		b = ac.lower(b)
+		for {
+			if next, ok := ac.nodes[state].next[b]; ok {
+				state = next
+				break
+			}
+			if state == 0 {
+				break
+			}
+			state = ac.nodes[state].fail
+		}
+
+		for _, id := range ac.nodes[state].out {
+			hit(id)
+		}
+	}
+}
+
+// lower returns b with ASCII upper case letters lowered if the automaton folds case.
+func (ac *ahoCorasick) lower(b byte) byte {
+	if ac.fold && 'A' <= b && b <= 'Z' {
+		return b + 'a' - 'A'
+	}
+	return b
+}
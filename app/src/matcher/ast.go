@@ -0,0 +1,200 @@
+package matcher
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// NodeKind identifies the kind of a node in a parsed pattern.
+type NodeKind int
+
+const (
+	NodeEmpty           NodeKind = iota // matches the empty string
+	NodeLiteral                         // a single literal character
+	NodeAnyChar                         // '.' in dot-all mode, matching any character
+	NodeAnyCharNotNL                    // '.', matching any character but '\n'
+	NodeEscape                          // an escape class such as \d or \w
+	NodeCharClass                       // a bracket expression such as [abc] or [^abc]
+	NodeBeginLine                       // '^' in multiline mode, matching at the start of every line
+	NodeEndLine                         // '$' in multiline mode, matching at the end of every line
+	NodeBeginText                       // '^' or \A, matching at the start of the input
+	NodeEndText                         // '$' or \z, matching at the end of the input
+	NodeWordBoundary                    // \b
+	NodeNotWordBoundary                 // \B
+	NodeRepeat                          // a quantified sub-expression
+	NodeConcat                          // a sequence of sub-expressions
+	NodeAlternate                       // alternatives separated by '|'
+	NodeCapture                         // a capturing group
+	NodeBackref                         // a backreference such as \1
+	NodeLook                            // a lookahead or lookbehind assertion
+)
+
+// RepeatMode controls how a quantifier trades repetitions against the rest of the pattern.
+type RepeatMode uint8
+
+const (
+	RepeatGreedy     RepeatMode = iota // as many repetitions as possible, giving back on failure
+	RepeatLazy                         // as few repetitions as possible, as in a*?
+	RepeatPossessive                   // as many repetitions as possible, never giving back, as in a*+
+)
+
+// LookKind identifies the direction and sense of a lookaround assertion.
+type LookKind uint8
+
+const (
+	LookAhead     LookKind = iota // (?=...)
+	LookAheadNot                  // (?!...)
+	LookBehind                    // (?<=...)
+	LookBehindNot                 // (?<!...)
+)
+
+// Node is a single node of the abstract syntax tree produced by parsing a pattern.
+type Node struct {
+	Kind     NodeKind
+	Char     rune       // NodeLiteral, NodeEscape
+	Class    *CharClass // NodeEscape, NodeCharClass members
+	Text     string     // NodeEscape, NodeCharClass source text
+	Min, Max int        // NodeRepeat; Max is -1 when unbounded
+	Mode     RepeatMode // NodeRepeat
+	Index    int        // NodeCapture, NodeBackref group number, starting at 1
+	Name     string     // NodeCapture group name, empty if unnamed
+	Look     LookKind   // NodeLook
+	Fold     bool       // NodeLiteral, NodeBackref: compare under Unicode case folding
+	Children []*Node    // NodeRepeat, NodeConcat, NodeAlternate, NodeCapture, NodeLook
+}
+
+// matchChar reports whether a single-character node matches the given character.
+func (n *Node) matchChar(char rune) bool {
+	switch n.Kind {
+	case NodeLiteral:
+		return char == n.Char || n.Fold && foldEqual(char, n.Char)
+	case NodeAnyChar:
+		return true
+	case NodeAnyCharNotNL:
+		return char != '\n'
+	case NodeEscape, NodeCharClass:
+		return n.Class.Contains(char)
+	default:
+		return false
+	}
+}
+
+// nullable reports whether the node can match the empty string.
+func (n *Node) nullable() bool {
+	switch n.Kind {
+	case NodeLiteral, NodeAnyChar, NodeAnyCharNotNL, NodeEscape, NodeCharClass:
+		return false
+	case NodeConcat:
+		for _, child := range n.Children {
+			if !child.nullable() {
+				return false
+			}
+		}
+		return true
+	case NodeAlternate:
+		for _, child := range n.Children {
+			if child.nullable() {
+				return true
+			}
+		}
+		return false
+	case NodeRepeat:
+		return n.Min == 0 || n.Children[0].nullable()
+	case NodeCapture:
+		return n.Children[0].nullable()
+	default:
+		return true
+	}
+}
+
+// width returns the fewest and the most bytes of text the node can match; the most is
+// -1 when there is no bound. Characters are counted as 1 to utf8.UTFMax bytes unless
+// the node only matches a single known one.
+func (n *Node) width() (int, int) {
+	switch n.Kind {
+	case NodeLiteral:
+		if size := utf8.RuneLen(n.Char); !n.Fold && size > 0 {
+			return size, size
+		}
+		return 1, utf8.UTFMax
+	case NodeAnyChar, NodeAnyCharNotNL, NodeEscape, NodeCharClass:
+		return 1, utf8.UTFMax
+	case NodeConcat:
+		lo, hi := 0, 0
+		for _, child := range n.Children {
+			clo, chi := child.width()
+			lo += clo
+			if hi == -1 || chi == -1 {
+				hi = -1
+			} else {
+				hi += chi
+			}
+		}
+		return lo, hi
+	case NodeAlternate:
+		lo, hi := -1, 0
+		for _, child := range n.Children {
+			clo, chi := child.width()
+			if lo == -1 || clo < lo {
+				lo = clo
+			}
+			if hi != -1 && (chi == -1 || chi > hi) {
+				hi = chi
+			}
+		}
+		return max(lo, 0), hi
+	case NodeRepeat:
+		clo, chi := n.Children[0].width()
+		switch {
+		case chi == 0:
+			return 0, 0
+		case chi == -1 || n.Max == -1:
+			return clo * n.Min, -1
+		default:
+			return clo * n.Min, chi * n.Max
+		}
+	case NodeCapture:
+		return n.Children[0].width()
+	case NodeBackref:
+		return 0, -1
+	default:
+		return 0, 0
+	}
+}
+
+// behind reports whether the lookaround looks at the text before the current position.
+func (k LookKind) behind() bool {
+	return k == LookBehind || k == LookBehindNot
+}
+
+// negated reports whether the lookaround succeeds when its body does not match.
+func (k LookKind) negated() bool {
+	return k == LookAheadNot || k == LookBehindNot
+}
+
+// String returns the pattern syntax that opens the lookaround.
+func (k LookKind) String() string {
+	return [...]string{"(?=", "(?!", "(?<=", "(?<!"}[k]
+}
+
+// charString returns the pattern syntax of a single-character node.
+func (n *Node) charString() string {
+	switch n.Kind {
+	case NodeLiteral:
+		if n.Fold {
+			return "(?i)" + strconv.QuoteRune(n.Char)
+		}
+		return strconv.QuoteRune(n.Char)
+	case NodeAnyChar:
+		return "(?s)."
+	case NodeAnyCharNotNL:
+		return "."
+	case NodeEscape, NodeCharClass:
+		if n.Class.Fold {
+			return "(?i)" + n.Text
+		}
+		return n.Text
+	default:
+		return "?"
+	}
+}
@@ -0,0 +1,277 @@
+package matcher
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// job is an entry on the backtracker's stack. It either resumes execution at pc and
+// pos, or, when restore is set, puts back the old value of a slot that a later
+// instruction overwrote.
+type job struct {
+	pc, pos   int
+	restore   bool
+	slot, old int
+}
+
+// backtracker executes a Prog depth first, trying the preferred branch of every split
+// before the other one. Unlike the Pike VM it keeps a single set of slots per path, so
+// it can evaluate backreferences, at the cost of exponential time in the worst case.
+type backtracker struct {
+	prog   *Prog
+	line   []byte
+	ncap   int
+	slots  []int
+	stack  []job
+	endAt  int // if not -1, InstMatch only succeeds at this offset
+	end    int // offset at which the last successful exec matched
+	budget *budget
+}
+
+func newBacktracker(prog *Prog, line []byte, ncap int, budget *budget) *backtracker {
+	return &backtracker{
+		prog:   prog,
+		line:   line,
+		ncap:   ncap,
+		slots:  make([]int, prog.NumSlots),
+		endAt:  -1,
+		budget: budget,
+	}
+}
+
+// run searches for the leftmost-first match starting at or after pos.
+//
+// Parameters:
+// - pos: The offset in the line at which the search starts.
+//
+// Returns:
+// - []int: The first ncap capture slots of the match, or nil if there is no match.
+func (b *backtracker) run(pos int) []int {
+	for start := pos; ; {
+		if b.tryAt(start) {
+			matched := make([]int, b.ncap)
+			copy(matched, b.slots)
+			return matched
+		}
+
+		_, width := decodeRune(b.line, start)
+		if width == 0 || b.budget.exceeded() != nil {
+			return nil
+		}
+		start += width
+	}
+}
+
+// tryAt reports whether the program matches starting exactly at pos. On success
+// the slots hold the offsets recorded along the matching path.
+func (b *backtracker) tryAt(pos int) bool {
+	for i := range b.slots {
+		b.slots[i] = -1
+	}
+	return b.exec(b.prog.Start, pos)
+}
+
+// exec reports whether the program matches when started at pc and pos with the
+// current slots.
+func (b *backtracker) exec(pc, pos int) bool {
+	b.stack = append(b.stack[:0], job{pc: pc, pos: pos})
+
+	for len(b.stack) > 0 {
+		j := b.stack[len(b.stack)-1]
+		b.stack = b.stack[:len(b.stack)-1]
+
+		if j.restore {
+			b.slots[j.slot] = j.old
+			continue
+		}
+
+		if b.step(j.pc, j.pos) {
+			return true
+		}
+	}
+	return false
+}
+
+// step follows a single path from pc until it matches or fails, pushing the
+// alternatives it skips onto the stack.
+func (b *backtracker) step(pc, pos int) bool {
+	for {
+		if !b.budget.spend() {
+			return false
+		}
+		inst := &b.prog.Insts[pc]
+
+		switch inst.Op {
+		case InstMatch:
+			if b.endAt != -1 && pos != b.endAt {
+				return false
+			}
+			b.end = pos
+			return true
+
+		case InstChar:
+			char, width := decodeRune(b.line, pos)
+			if width == 0 || !inst.Node.matchChar(char) {
+				return false
+			}
+			pos += width
+
+		case InstSplit:
+			b.stack = append(b.stack, job{pc: inst.Arg, pos: pos})
+
+		case InstJmp:
+
+		case InstAssert:
+			if !assertAt(inst.Assert, b.line, pos) {
+				return false
+			}
+
+		case InstSave:
+			b.stack = append(b.stack, job{restore: true, slot: inst.Arg, old: b.slots[inst.Arg]})
+			b.slots[inst.Arg] = pos
+
+		case InstProgress:
+			if b.slots[inst.Arg] == pos {
+				return false
+			}
+
+		case InstBackref:
+			start, end := b.slots[2*inst.Arg], b.slots[2*inst.Arg+1]
+			if start < 0 || end < 0 {
+				return false
+			}
+
+			end = matchCaptured(b.line, pos, b.line[start:end], inst.Node.Fold)
+			if end < 0 {
+				return false
+			}
+			pos = end
+
+		case InstLook:
+			ok, slots := lookAt(b.prog, b.line, inst, pos, b.slots, b.budget)
+			if !ok {
+				return false
+			}
+			if slots != nil {
+				b.adopt(slots)
+			}
+
+		case InstAtomic:
+			end, ok := b.atomic(inst, pos)
+			if !ok {
+				return false
+			}
+			pos = end
+		}
+
+		pc = inst.Out
+	}
+}
+
+// matchCaptured checks whether the text at pos repeats the captured text, comparing
+// character by character under case folding if fold is set, since case variants may
+// differ in length.
+//
+// Returns:
+// - int: The offset just past the repeated text, or -1 if it does not repeat.
+func matchCaptured(line []byte, pos int, captured []byte, fold bool) int {
+	if !fold {
+		if !bytes.HasPrefix(line[pos:], captured) {
+			return -1
+		}
+		return pos + len(captured)
+	}
+
+	for i := 0; i < len(captured); {
+		want, width := decodeRune(captured, i)
+		char, n := decodeRune(line, pos)
+		if n == 0 || !foldEqual(char, want) {
+			return -1
+		}
+		i += width
+		pos += n
+	}
+	return pos
+}
+
+// atomic runs the body of an InstAtomic instruction at pos and keeps only its first
+// match: the capture groups it set are copied into the current slots, and the
+// alternatives it skipped are thrown away so they can never be backtracked into.
+//
+// Returns:
+// - int: The offset at which the body matched.
+// - bool: True if the body matched, false otherwise.
+func (b *backtracker) atomic(inst *Inst, pos int) (int, bool) {
+	sub := newBacktracker(b.prog, b.line, 0, b.budget)
+	copy(sub.slots, b.slots)
+	if !sub.exec(inst.Arg, pos) {
+		return 0, false
+	}
+
+	b.adopt(sub.slots)
+	return sub.end, true
+}
+
+// adopt takes the capture slots a body set in a sub-backtracker, pushing restore jobs
+// so that backtracking past the body undoes them.
+func (b *backtracker) adopt(slots []int) {
+	for i := 0; i < b.prog.NumCap; i++ {
+		if slots[i] != b.slots[i] {
+			b.stack = append(b.stack, job{restore: true, slot: i, old: b.slots[i]})
+			b.slots[i] = slots[i]
+		}
+	}
+}
+
+// lookAt evaluates a lookaround instruction at pos. The body starts from a copy of the
+// given slots, so backreferences in it see the groups matched so far. When a positive
+// lookaround holds, the slots its body matched with are returned, so groups set inside
+// it are visible after it; a negative one never sets any.
+//
+// A lookbehind succeeds if the body can match some text that ends exactly at pos,
+// which is checked by trying the start positions from pos back as far as the longest
+// text the body can match, or back to the start of the line if its length is unbounded.
+// Starts closer to pos than the shortest text the body can match are skipped.
+//
+// Parameters:
+// - prog: The program holding the lookaround body.
+// - line: The byte slice representing the line to be checked.
+// - inst: The InstLook instruction.
+// - pos: The offset in the line at which the assertion is evaluated.
+// - slots: The capture slots recorded so far, or nil if there are none.
+// - budget: The budget of the enclosing search, spent by the body too.
+//
+// Returns:
+// - bool: True if the assertion holds at pos, false otherwise.
+// - []int: The slots the body matched with if a positive lookaround holds, or nil.
+func lookAt(prog *Prog, line []byte, inst *Inst, pos int, slots []int, budget *budget) (bool, []int) {
+	sub := newBacktracker(prog, line, 0, budget)
+	matchFrom := func(start int) bool {
+		for i := range sub.slots {
+			sub.slots[i] = -1
+		}
+		copy(sub.slots, slots)
+		return sub.exec(inst.Arg, start)
+	}
+
+	matched := false
+	if !inst.Look.behind() {
+		matched = matchFrom(pos)
+	} else {
+		sub.endAt = pos
+		first := 0
+		if inst.Max != -1 {
+			first = max(pos-inst.Max, 0)
+		}
+		for start := pos - inst.Min; start >= first && !matched; start-- {
+			if start == len(line) || utf8.RuneStart(line[start]) {
+				matched = matchFrom(start)
+			}
+		}
+	}
+
+	if !matched || inst.Look.negated() {
+		return matched != inst.Look.negated(), nil
+	}
+	return true, sub.slots
+}
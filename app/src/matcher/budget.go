@@ -0,0 +1,76 @@
+package matcher
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrMatchBudgetExceeded is returned when a search takes more steps than the
+// pattern's MaxSteps allows, or when its context is done before the search finishes.
+var ErrMatchBudgetExceeded = errors.New("match step budget exceeded")
+
+// budgetCheckInterval is the number of steps between two checks of the context, which
+// are too slow to make on every step.
+const budgetCheckInterval = 1024
+
+// budget limits the work done by a single search. Every engine spends one step per
+// instruction or character it processes and stops as soon as spend fails. A nil
+// budget never runs out.
+type budget struct {
+	ctx        context.Context
+	left       int // steps left, or -1 if unlimited
+	sinceCheck int // steps since the context was last checked
+	err        error
+}
+
+// newBudget returns a budget allowing maxSteps steps, or any number if maxSteps is 0,
+// until ctx is done. It returns nil if neither limit applies.
+func newBudget(ctx context.Context, maxSteps int) *budget {
+	if ctx == nil && maxSteps <= 0 {
+		return nil
+	}
+
+	b := &budget{ctx: ctx, left: -1}
+	if maxSteps > 0 {
+		b.left = maxSteps
+	}
+	return b
+}
+
+// spend takes one step from the budget and reports whether the search may go on.
+func (b *budget) spend() bool {
+	if b == nil {
+		return true
+	}
+	if b.err != nil {
+		return false
+	}
+
+	if b.left == 0 {
+		b.err = ErrMatchBudgetExceeded
+		return false
+	}
+	if b.left > 0 {
+		b.left--
+	}
+
+	if b.ctx != nil {
+		if b.sinceCheck++; b.sinceCheck >= budgetCheckInterval {
+			b.sinceCheck = 0
+			if err := b.ctx.Err(); err != nil {
+				b.err = fmt.Errorf("%w: %w", ErrMatchBudgetExceeded, err)
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// exceeded returns the error the search stopped with, or nil if the budget did not run out.
+func (b *budget) exceeded() error {
+	if b == nil {
+		return nil
+	}
+	return b.err
+}
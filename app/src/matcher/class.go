@@ -0,0 +1,191 @@
+package matcher
+
+import "unicode"
+
+// RuneRange is an inclusive range of characters.
+type RuneRange struct {
+	Lo, Hi rune
+}
+
+// CharClass is a set of characters built from a bracket expression or an escape class.
+// A character is a member if it falls in one of the Ranges, one of the Tables or one of
+// the Subclasses; Negated inverts the result. When Fold is set, a character is also a
+// member if any of its case variants is, as in (?i)[a-z].
+type CharClass struct {
+	Ranges     []RuneRange
+	Tables     []*unicode.RangeTable
+	Subclasses []*CharClass // negated classes nested in a bracket, such as \P{Greek} in [\P{Greek}]
+	Negated    bool
+	Fold       bool
+}
+
+// Contains reports whether the character is a member of the class.
+//
+// Parameters:
+// - char: The character to be checked.
+//
+// Returns:
+// - bool: True if the character matches the class, false otherwise.
+func (c *CharClass) Contains(char rune) bool {
+	if c.Fold {
+		return c.hasFolded(char) != c.Negated
+	}
+	return c.has(char) != c.Negated
+}
+
+// hasFolded reports whether the character or one of its case variants falls in the
+// class, ignoring negation.
+func (c *CharClass) hasFolded(char rune) bool {
+	if c.has(char) {
+		return true
+	}
+	for f := unicode.SimpleFold(char); f != char; f = unicode.SimpleFold(f) {
+		if c.has(f) {
+			return true
+		}
+	}
+	return false
+}
+
+// folded returns a copy of the class that matches case-insensitively. Escape classes
+// such as \w are shared, so they are never changed in place.
+func (c *CharClass) folded() *CharClass {
+	class := *c
+	class.Fold = true
+	return &class
+}
+
+// has reports whether the character falls in the ranges or tables of the class,
+// ignoring negation.
+func (c *CharClass) has(char rune) bool {
+	for _, r := range c.Ranges {
+		if char >= r.Lo && char <= r.Hi {
+			return true
+		}
+	}
+	for _, t := range c.Tables {
+		if unicode.Is(t, char) {
+			return true
+		}
+	}
+	for _, sub := range c.Subclasses {
+		if sub.Contains(char) {
+			return true
+		}
+	}
+	return false
+}
+
+// merge adds the members of other to the class.
+func (c *CharClass) merge(other *CharClass) {
+	if other.Negated {
+		c.Subclasses = append(c.Subclasses, other)
+		return
+	}
+	c.Ranges = append(c.Ranges, other.Ranges...)
+	c.Tables = append(c.Tables, other.Tables...)
+	c.Subclasses = append(c.Subclasses, other.Subclasses...)
+}
+
+// escapeClass returns the class matched by an escape such as \d or \w,
+// or nil if the escape does not stand for a class.
+//
+// Parameters:
+// - escapeChar: The character following the backslash.
+//
+// Returns:
+// - *CharClass: The class of characters matched by the escape, or nil.
+func escapeClass(escapeChar byte) *CharClass {
+	switch escapeChar {
+	case Digit:
+		return &CharClass{Tables: []*unicode.RangeTable{unicode.Digit}}
+	case AlphaNumeric:
+		return wordClass
+	case Whitespace:
+		return &CharClass{Tables: []*unicode.RangeTable{unicode.White_Space}}
+	case NotDigit, NotAlphaNumeric, NotWhitespace:
+		class := *escapeClass(byte(unicode.ToLower(rune(escapeChar))))
+		class.Negated = true
+		return &class
+	default:
+		return nil
+	}
+}
+
+// wordClass is the class matched by \w, also used to find word boundaries for \b.
+var wordClass = &CharClass{
+	Ranges: []RuneRange{{'_', '_'}},
+	Tables: []*unicode.RangeTable{unicode.Letter, unicode.Digit},
+}
+
+// isWordChar reports whether the character is matched by \w.
+func isWordChar(char rune) bool {
+	return wordClass.Contains(char)
+}
+
+// foldEqual reports whether two characters are equal under Unicode simple case folding,
+// as 'k', 'K' and the Kelvin sign 'K' are.
+func foldEqual(a, b rune) bool {
+	if a == b {
+		return true
+	}
+	for f := unicode.SimpleFold(a); f != a; f = unicode.SimpleFold(f) {
+		if f == b {
+			return true
+		}
+	}
+	return false
+}
+
+// canFold reports whether the character has case variants.
+func canFold(char rune) bool {
+	return unicode.SimpleFold(char) != char
+}
+
+// escapeLiterals maps escapes for control characters, such as \t, to the character they stand for.
+var escapeLiterals = map[byte]rune{
+	'a': '\a',
+	'f': '\f',
+	'n': '\n',
+	'r': '\r',
+	't': '\t',
+	'v': '\v',
+}
+
+// anyRune is the table used for \p{Any}.
+var anyRune = &unicode.RangeTable{
+	R32: []unicode.Range32{{Lo: 0, Hi: unicode.MaxRune, Stride: 1}},
+}
+
+// unicodeTable returns the table for a Unicode general category such as L or Lu, or a
+// script such as Greek, as named in \p{...}. It returns nil if the name is unknown.
+func unicodeTable(name string) *unicode.RangeTable {
+	if name == "Any" {
+		return anyRune
+	}
+	if table, ok := unicode.Categories[name]; ok {
+		return table
+	}
+	if table, ok := unicode.Scripts[name]; ok {
+		return table
+	}
+	return nil
+}
+
+// posixClasses maps the names usable in [[:name:]] to the ASCII characters they match.
+var posixClasses = map[string][]RuneRange{
+	"alnum":  {{'0', '9'}, {'A', 'Z'}, {'a', 'z'}},
+	"alpha":  {{'A', 'Z'}, {'a', 'z'}},
+	"ascii":  {{0x00, 0x7F}},
+	"blank":  {{'\t', '\t'}, {' ', ' '}},
+	"cntrl":  {{0x00, 0x1F}, {0x7F, 0x7F}},
+	"digit":  {{'0', '9'}},
+	"graph":  {{'!', '~'}},
+	"lower":  {{'a', 'z'}},
+	"print":  {{' ', '~'}},
+	"punct":  {{'!', '/'}, {':', '@'}, {'[', '`'}, {'{', '~'}},
+	"space":  {{'\t', '\r'}, {' ', ' '}},
+	"upper":  {{'A', 'Z'}},
+	"word":   {{'0', '9'}, {'A', 'Z'}, {'_', '_'}, {'a', 'z'}},
+	"xdigit": {{'0', '9'}, {'A', 'F'}, {'a', 'f'}},
+}
@@ -0,0 +1,299 @@
+package matcher
+
+import (
+	"encoding/binary"
+	"sort"
+	"unicode/utf8"
+)
+
+const (
+	maxDFAStates = 4096 // states kept in a DFA cache before it is cleared
+	maxDFAResets = 4    // cache clears allowed during one search before falling back to the NFA
+)
+
+// dfaFlags describes the text just before a position, which decides the zero-width
+// assertions that can hold there together with the character that follows.
+type dfaFlags uint8
+
+const (
+	flagBeginText    dfaFlags = 1 << iota // the position is the start of the line
+	flagAfterNewline                      // the previous character is '\n'
+	flagAfterWord                         // the previous character is a word character
+)
+
+// dfaState is a set of NFA instructions waiting to have their empty transitions
+// followed, together with the flags of the position they are at. Transitions to the
+// following states are filled in as characters are seen.
+type dfaState struct {
+	pcs   []int
+	flags dfaFlags
+	ascii [utf8.RuneSelf]*dfaState
+	other map[rune]*dfaState
+	final int8 // 1 if the state matches at the end of the line, -1 if not, 0 if not computed yet
+}
+
+// dfa is a deterministic automaton built lazily from a Prog, one state at a time, as the
+// lines being searched need them. It only answers whether a line contains a match,
+// which is all a grep needs for most lines, and leaves finding offsets and capture
+// groups to the NFA.
+//
+// States are kept in a cache of at most maxDFAStates entries that is cleared when it
+// fills up. A pattern whose states keep thrashing the cache, such as (a|b)*a(a|b){20},
+// gives up after maxDFAResets clears so the search can fall back to the NFA.
+//
+// A dfa is not safe for concurrent use; Regexp keeps a pool of them.
+type dfa struct {
+	prog     *Prog
+	anchored bool // the program can only match at the start of the line
+	states   map[string]*dfaState
+	start    *dfaState
+	resets   int
+
+	matched *dfaState // sentinel reached as soon as a match is found
+	dead    *dfaState // sentinel reached when no match is possible any more
+
+	stack []int
+	seen  []uint32 // generation in which each instruction was last visited by closure
+	gen   uint32
+	key   []byte
+}
+
+// canUseDFA reports whether the program can run on a DFA: lookarounds, backreferences
+// and possessive quantifiers need the backtracker.
+func canUseDFA(prog *Prog) bool {
+	if prog.Backtrack {
+		return false
+	}
+	for _, inst := range prog.Insts {
+		if inst.Op == InstLook || inst.Op == InstAtomic {
+			return false
+		}
+	}
+	return true
+}
+
+func newDFA(prog *Prog) *dfa {
+	return &dfa{
+		prog:     prog,
+		anchored: anchoredAtStart(prog),
+		states:   make(map[string]*dfaState),
+		matched:  &dfaState{},
+		dead:     &dfaState{},
+		seen:     make([]uint32, len(prog.Insts)),
+	}
+}
+
+// anchoredAtStart reports whether every path through the program begins with a '^'
+// that only matches at the start of the line.
+func anchoredAtStart(prog *Prog) bool {
+	for pc := prog.Start; ; {
+		inst := &prog.Insts[pc]
+		switch inst.Op {
+		case InstSave, InstJmp:
+			pc = inst.Out
+		case InstAssert:
+			return inst.Assert == AssertBeginText
+		default:
+			return false
+		}
+	}
+}
+
+// match reports whether the line contains a match of the program.
+//
+// Parameters:
+// - line: The byte slice representing the line to be checked.
+// - budget: The budget of the search, spent once per character.
+//
+// Returns:
+// - bool: True if the line contains a match, false otherwise or if the budget ran out.
+// - bool: False if the cache thrashed and the DFA gave up, in which case the first result is meaningless.
+func (d *dfa) match(line []byte, budget *budget) (bool, bool) {
+	d.resets = 0
+	s := d.start
+	if s == nil {
+		if s = d.lookup([]int{d.prog.Start}, flagBeginText); s == nil {
+			return false, false
+		}
+		d.start = s
+	}
+
+	for pos := 0; pos < len(line); {
+		if !budget.spend() {
+			return false, true
+		}
+		char, width := decodeRune(line, pos)
+
+		var next *dfaState
+		if char < utf8.RuneSelf {
+			next = s.ascii[char]
+		} else {
+			next = s.other[char]
+		}
+
+		if next == nil {
+			if next = d.step(s, char); next == nil {
+				return false, false
+			}
+			if char < utf8.RuneSelf {
+				s.ascii[char] = next
+			} else {
+				if s.other == nil {
+					s.other = make(map[rune]*dfaState)
+				}
+				s.other[char] = next
+			}
+		}
+
+		switch next {
+		case d.matched:
+			return true, true
+		case d.dead:
+			return false, true
+		}
+		s = next
+		pos += width
+	}
+
+	if s.final == 0 {
+		s.final = -1
+		if _, ok := d.closure(s, 0, true); ok {
+			s.final = 1
+		}
+	}
+	return s.final == 1, true
+}
+
+// step computes the state reached from s by consuming char. It returns d.matched if a
+// match ends right before char, and nil if the cache had to be cleared too many times.
+func (d *dfa) step(s *dfaState, char rune) *dfaState {
+	chars, matched := d.closure(s, char, false)
+	if matched {
+		return d.matched
+	}
+
+	var pcs []int
+	for _, pc := range chars {
+		inst := &d.prog.Insts[pc]
+		if inst.Node.matchChar(char) {
+			pcs = append(pcs, inst.Out)
+		}
+	}
+	if !d.anchored {
+		pcs = append(pcs, d.prog.Start)
+	}
+	if len(pcs) == 0 {
+		return d.dead
+	}
+
+	var flags dfaFlags
+	if char == '\n' {
+		flags |= flagAfterNewline
+	}
+	if isWordChar(char) {
+		flags |= flagAfterWord
+	}
+	return d.lookup(pcs, flags)
+}
+
+// closure follows the empty transitions from the instructions of s, given the
+// character that follows the position, or the end of the line if atEnd is set.
+//
+// Returns:
+// - []int: The InstChar instructions reached.
+// - bool: True if an InstMatch was reached.
+func (d *dfa) closure(s *dfaState, next rune, atEnd bool) ([]int, bool) {
+	d.gen++
+	if d.gen == 0 {
+		clear(d.seen)
+		d.gen = 1
+	}
+
+	var chars []int
+	for i := len(s.pcs) - 1; i >= 0; i-- {
+		d.stack = append(d.stack, s.pcs[i])
+	}
+
+	for len(d.stack) > 0 {
+		pc := d.stack[len(d.stack)-1]
+		d.stack = d.stack[:len(d.stack)-1]
+		if d.seen[pc] == d.gen {
+			continue
+		}
+		d.seen[pc] = d.gen
+
+		inst := &d.prog.Insts[pc]
+		switch inst.Op {
+		case InstMatch:
+			d.stack = d.stack[:0]
+			return nil, true
+		case InstChar:
+			if !atEnd {
+				chars = append(chars, pc)
+			}
+		case InstSplit:
+			d.stack = append(d.stack, inst.Arg, inst.Out)
+		case InstJmp, InstSave, InstProgress:
+			d.stack = append(d.stack, inst.Out)
+		case InstAssert:
+			if assertFlags(inst.Assert, s.flags, next, atEnd) {
+				d.stack = append(d.stack, inst.Out)
+			}
+		}
+	}
+	return chars, false
+}
+
+// assertFlags reports whether a zero-width assertion holds at a position described by
+// the flags of the text before it and by the character after it.
+func assertFlags(kind AssertKind, flags dfaFlags, next rune, atEnd bool) bool {
+	switch kind {
+	case AssertBeginLine:
+		return flags&(flagBeginText|flagAfterNewline) != 0
+	case AssertEndLine:
+		return atEnd || next == '\n'
+	case AssertBeginText:
+		return flags&flagBeginText != 0
+	case AssertEndText:
+		return atEnd
+	case AssertWordBoundary, AssertNotWordBoundary:
+		before := flags&flagAfterWord != 0
+		after := !atEnd && isWordChar(next)
+		return (before != after) == (kind == AssertWordBoundary)
+	default:
+		return false
+	}
+}
+
+// lookup returns the cached state for the instructions and flags, adding it if it is
+// new. When the cache is full it is cleared first; it returns nil once that has
+// happened more than maxDFAResets times in the current search.
+func (d *dfa) lookup(pcs []int, flags dfaFlags) *dfaState {
+	sort.Ints(pcs)
+	unique := pcs[:0]
+	for i, pc := range pcs {
+		if i == 0 || pc != pcs[i-1] {
+			unique = append(unique, pc)
+		}
+	}
+
+	d.key = append(d.key[:0], byte(flags))
+	for _, pc := range unique {
+		d.key = binary.AppendUvarint(d.key, uint64(pc))
+	}
+	if s, ok := d.states[string(d.key)]; ok {
+		return s
+	}
+
+	if len(d.states) >= maxDFAStates {
+		if d.resets++; d.resets > maxDFAResets {
+			return nil
+		}
+		d.states = make(map[string]*dfaState)
+		d.start = nil
+	}
+
+	s := &dfaState{pcs: unique, flags: flags}
+	d.states[string(d.key)] = s
+	return s
+}
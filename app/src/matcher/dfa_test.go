@@ -0,0 +1,114 @@
+package matcher
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestDFAMatchesNFA(t *testing.T) {
+	patterns := []string{
+		"go",
+		"^main",
+		"\\.go$",
+		"^[^.]+\\.go$",
+		"a|b|cd",
+		"(ab)*c",
+		"x*",
+		"",
+		"\\bcat\\b",
+		"\\Bat",
+		"(?m)^b$",
+		"(?s)a.c",
+		"a.c",
+		"(?i)été",
+		"\\d{2,3}",
+		"[α-ω]+s",
+		"a??b",
+	}
+	lines := []string{
+		"",
+		"main.go",
+		"main_test.go",
+		"README.md",
+		"cat concat cats",
+		"a\nb\nc",
+		"abababc",
+		"ÉTÉ",
+		"12 345 6789",
+		"αβγs",
+		"\xffab",
+	}
+
+	for _, pattern := range patterns {
+		re := MustCompile(pattern)
+		if re.dfas == nil {
+			t.Fatalf("%q: want the pattern to run on a DFA", pattern)
+		}
+
+		for _, line := range lines {
+			vm := newPikeVM(re.prog)
+			vm.reset([]byte(line), 2, nil)
+			want := vm.run(0) != nil
+			got, ok := re.dfaMatch([]byte(line), nil)
+			if !ok || got != want {
+				t.Errorf("dfaMatch(%q, %q) = %v, %v, want %v, true", pattern, line, got, ok, want)
+			}
+		}
+	}
+}
+
+func TestDFANotUsed(t *testing.T) {
+	for _, pattern := range []string{"(a)\\1", "a++", "(?=a)", "(?<!a)b"} {
+		if MustCompile(pattern).dfas != nil {
+			t.Errorf("%q: want the pattern to skip the DFA", pattern)
+		}
+	}
+}
+
+func TestDFAFallsBackWhenCacheThrashes(t *testing.T) {
+	// Every position in a random a/b line leads to a new state: the DFA for this
+	// pattern has 2^20 states, far more than the cache holds.
+	re := MustCompile("(a|b)*a(a|b){20}c")
+
+	var line bytes.Buffer
+	for seed := uint32(1); line.Len() < 100000; {
+		seed = seed*1664525 + 1013904223
+		line.WriteByte("ab"[seed>>31])
+	}
+
+	if _, ok := re.dfaMatch(line.Bytes(), nil); ok {
+		t.Errorf("dfaMatch() ok = true, want the DFA to give up")
+	}
+	if re.Match(line.Bytes()) {
+		t.Errorf("Match() = true, want false")
+	}
+
+	line.WriteString("a" + strings.Repeat("b", 20) + "c")
+	if !re.Match(line.Bytes()) {
+		t.Errorf("Match() = false, want true")
+	}
+}
+
+// benchLine is a long log line with no match for benchPattern.
+var benchLine = []byte(strings.Repeat("2024-01-02T15:04:05Z INFO request served in 12ms path=/api/v1/items ", 200))
+
+const benchPattern = "ERROR|panic: .*|status=5\\d\\d"
+
+func BenchmarkMatchDFA(b *testing.B) {
+	re := MustCompile(benchPattern)
+	b.SetBytes(int64(len(benchLine)))
+	for i := 0; i < b.N; i++ {
+		re.dfaMatch(benchLine, nil)
+	}
+}
+
+func BenchmarkMatchPikeVM(b *testing.B) {
+	re := MustCompile(benchPattern)
+	vm := newPikeVM(re.prog)
+	b.SetBytes(int64(len(benchLine)))
+	for i := 0; i < b.N; i++ {
+		vm.reset(benchLine, 2, nil)
+		vm.run(0)
+	}
+}
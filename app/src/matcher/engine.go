@@ -0,0 +1,235 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"time"
+	"unicode/utf8"
+
+	"github.com/dlclark/regexp2"
+)
+
+const (
+	EngineBuiltin = "builtin" // this package's own engine
+	EngineRE2     = "re2"     // Go's regexp package, linear time and no backreferences
+	EnginePCRE    = "pcre"    // github.com/dlclark/regexp2, with lookarounds and backreferences
+)
+
+// Pattern is a compiled pattern that can be matched against lines, whichever Engine compiled it.
+type Pattern interface {
+	// Match reports whether the line contains any match of the pattern.
+	Match(line []byte) bool
+	// FindIndex returns the byte offsets of the leftmost match in the line, or nil if there is none.
+	FindIndex(line []byte) []int
+	// FindAllIndex returns the byte offsets of successive non-overlapping matches in the line,
+	// at most n of them if n >= 0, or nil if there is none.
+	FindAllIndex(line []byte, n int) [][]int
+	// String returns the source pattern.
+	String() string
+}
+
+// ContextPattern is a Pattern that can report why a search gave up, as the built-in
+// and pcre engines' patterns do.
+type ContextPattern interface {
+	Pattern
+	// MatchContext is like Match but returns an error wrapping ErrMatchBudgetExceeded
+	// if the search ran out of steps or time.
+	MatchContext(ctx context.Context, line []byte) (bool, error)
+}
+
+// Engine compiles patterns written in its dialect into Patterns.
+type Engine interface {
+	// Name returns the name used to select the engine, such as "re2".
+	Name() string
+	// Compile parses a pattern and returns it ready for matching.
+	Compile(pattern string) (Pattern, error)
+	// CompileWithOptions is like Compile but starts the pattern with the given flags set.
+	CompileWithOptions(pattern string, opts CompileOptions) (Pattern, error)
+}
+
+// Engines returns the names of all available engines.
+func Engines() []string {
+	return []string{EngineBuiltin, EngineRE2, EnginePCRE}
+}
+
+// NewEngine returns the engine with the given name. An empty name selects the built-in engine.
+//
+// Parameters:
+// - name: The engine name, one of EngineBuiltin, EngineRE2 or EnginePCRE.
+//
+// Returns:
+// - Engine: The selected engine.
+// - error: An error if no engine has that name.
+func NewEngine(name string) (Engine, error) {
+	switch name {
+	case "", EngineBuiltin:
+		return builtinEngine{}, nil
+	case EngineRE2:
+		return re2Engine{}, nil
+	case EnginePCRE:
+		return pcreEngine{}, nil
+	default:
+		return nil, fmt.Errorf("unknown engine %q (available: %v)", name, Engines())
+	}
+}
+
+// builtinEngine compiles patterns with Compile.
+type builtinEngine struct{}
+
+func (builtinEngine) Name() string {
+	return EngineBuiltin
+}
+
+func (e builtinEngine) Compile(pattern string) (Pattern, error) {
+	return e.CompileWithOptions(pattern, CompileOptions{})
+}
+
+func (builtinEngine) CompileWithOptions(pattern string, opts CompileOptions) (Pattern, error) {
+	re, err := CompileWithOptions(pattern, opts)
+	if err != nil {
+		return nil, err
+	}
+	return re, nil
+}
+
+// re2Engine compiles patterns with Go's regexp package.
+type re2Engine struct{}
+
+func (re2Engine) Name() string {
+	return EngineRE2
+}
+
+func (e re2Engine) Compile(pattern string) (Pattern, error) {
+	return e.CompileWithOptions(pattern, CompileOptions{})
+}
+
+// CompileWithOptions turns the options into a leading inline flag group, as regexp has no
+// other way to set them.
+func (re2Engine) CompileWithOptions(pattern string, opts CompileOptions) (Pattern, error) {
+	flags := ""
+	if opts.CaseInsensitive {
+		flags += "i"
+	}
+	if opts.Multiline {
+		flags += "m"
+	}
+	if opts.DotAll {
+		flags += "s"
+	}
+	if flags != "" {
+		pattern = "(?" + flags + ")" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return re, nil
+}
+
+// pcreStepTime is how long a regexp2 search may run per step of CompileOptions.MaxSteps.
+// regexp2 cannot count steps, only limit the time a search takes, which it checks about
+// every 100ms.
+const pcreStepTime = 100 * time.Nanosecond
+
+// pcreEngine compiles patterns with regexp2, a backtracking engine compatible with Perl and .NET.
+type pcreEngine struct{}
+
+func (pcreEngine) Name() string {
+	return EnginePCRE
+}
+
+func (e pcreEngine) Compile(pattern string) (Pattern, error) {
+	return e.CompileWithOptions(pattern, CompileOptions{})
+}
+
+func (pcreEngine) CompileWithOptions(pattern string, opts CompileOptions) (Pattern, error) {
+	flags := regexp2.None
+	if opts.CaseInsensitive {
+		flags |= regexp2.IgnoreCase
+	}
+	if opts.Multiline {
+		flags |= regexp2.Multiline
+	}
+	if opts.DotAll {
+		flags |= regexp2.Singleline
+	}
+
+	re, err := regexp2.Compile(pattern, flags)
+	if err != nil {
+		return nil, err
+	}
+	if opts.MaxSteps > 0 {
+		re.MatchTimeout = time.Duration(opts.MaxSteps) * pcreStepTime
+	}
+	return pcrePattern{re: re}, nil
+}
+
+// pcrePattern adapts a regexp2.Regexp, which matches runes, to the byte-oriented Pattern
+// interface. A match that fails with an error, such as a timeout, counts as no match.
+type pcrePattern struct {
+	re *regexp2.Regexp
+}
+
+func (p pcrePattern) Match(line []byte) bool {
+	ok, err := p.re.MatchRunes([]rune(string(line)))
+	return err == nil && ok
+}
+
+// MatchContext is like Match but returns an error wrapping ErrMatchBudgetExceeded if
+// the search timed out. regexp2 cannot be interrupted, so ctx is only checked before
+// the search starts.
+func (p pcrePattern) MatchContext(ctx context.Context, line []byte) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, fmt.Errorf("%w: %w", ErrMatchBudgetExceeded, err)
+	}
+
+	ok, err := p.re.MatchRunes([]rune(string(line)))
+	if err != nil {
+		return false, fmt.Errorf("%w: %w", ErrMatchBudgetExceeded, err)
+	}
+	return ok, nil
+}
+
+func (p pcrePattern) FindIndex(line []byte) []int {
+	m, err := p.re.FindRunesMatch([]rune(string(line)))
+	if err != nil || m == nil {
+		return nil
+	}
+
+	start := runeToByteOffset(line, 0, 0, m.Index)
+	end := runeToByteOffset(line, start, m.Index, m.Index+m.Length)
+	return []int{start, end}
+}
+
+func (p pcrePattern) FindAllIndex(line []byte, n int) [][]int {
+	m, err := p.re.FindRunesMatch([]rune(string(line)))
+
+	var matches [][]int
+	offset, runeIdx := 0, 0
+	for err == nil && m != nil && (n < 0 || len(matches) < n) {
+		start := runeToByteOffset(line, offset, runeIdx, m.Index)
+		end := runeToByteOffset(line, start, m.Index, m.Index+m.Length)
+		matches = append(matches, []int{start, end})
+
+		offset, runeIdx = start, m.Index
+		m, err = p.re.FindNextMatch(m)
+	}
+	return matches
+}
+
+func (p pcrePattern) String() string {
+	return p.re.String()
+}
+
+// runeToByteOffset converts a rune index into a byte offset in line, starting from a
+// known byte offset and rune index pair.
+func runeToByteOffset(line []byte, offset, runeIdx, target int) int {
+	for runeIdx < target && offset < len(line) {
+		_, width := utf8.DecodeRune(line[offset:])
+		offset += width
+		runeIdx++
+	}
+	return offset
+}
@@ -0,0 +1,172 @@
+package matcher
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEngines(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		line    string
+		want    []int
+	}{
+		{
+			name:    "literal",
+			pattern: "go",
+			line:    "main.go",
+			want:    []int{5, 7},
+		},
+		{
+			name:    "class and quantifier",
+			pattern: "\\d+",
+			line:    "v123",
+			want:    []int{1, 4},
+		},
+		{
+			name:    "offsets after multibyte characters",
+			pattern: "b+",
+			line:    "ébb",
+			want:    []int{2, 4},
+		},
+		{
+			name:    "no match",
+			pattern: "xyz",
+			line:    "abc",
+			want:    nil,
+		},
+	}
+
+	for _, name := range Engines() {
+		engine, err := NewEngine(name)
+		if err != nil {
+			t.Fatalf("NewEngine(%q) error = %v", name, err)
+		}
+
+		for _, tt := range tests {
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				pattern, err := engine.Compile(tt.pattern)
+				if err != nil {
+					t.Fatalf("Compile(%q) error = %v", tt.pattern, err)
+				}
+
+				if got := pattern.FindIndex([]byte(tt.line)); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("FindIndex() = %v, want %v", got, tt.want)
+				}
+
+				var wantAll [][]int
+				if tt.want != nil {
+					wantAll = [][]int{tt.want}
+				}
+				if got := pattern.FindAllIndex([]byte(tt.line), -1); !reflect.DeepEqual(got, wantAll) {
+					t.Errorf("FindAllIndex() = %v, want %v", got, wantAll)
+				}
+
+				if got := pattern.Match([]byte(tt.line)); got != (tt.want != nil) {
+					t.Errorf("Match() = %v, want %v", got, tt.want != nil)
+				}
+			})
+		}
+	}
+}
+
+func TestEnginesFindAllIndex(t *testing.T) {
+	line := []byte("é1é22é333")
+	want := [][]int{{2, 3}, {5, 7}, {9, 12}}
+
+	for _, name := range Engines() {
+		engine, _ := NewEngine(name)
+		pattern, err := engine.Compile("\\d+")
+		if err != nil {
+			t.Fatalf("%s: Compile() error = %v", name, err)
+		}
+
+		if got := pattern.FindAllIndex(line, -1); !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: FindAllIndex() = %v, want %v", name, got, want)
+		}
+		if got := pattern.FindAllIndex(line, 2); !reflect.DeepEqual(got, want[:2]) {
+			t.Errorf("%s: FindAllIndex(n=2) = %v, want %v", name, got, want[:2])
+		}
+	}
+}
+
+func TestNewEngine(t *testing.T) {
+	engine, err := NewEngine("")
+	if err != nil || engine.Name() != EngineBuiltin {
+		t.Errorf("NewEngine(\"\") = %v, %v, want the built-in engine", engine, err)
+	}
+
+	if _, err := NewEngine("perl"); err == nil {
+		t.Errorf("NewEngine(\"perl\") error = nil, want an error")
+	}
+}
+
+func TestPCREEngineLookaround(t *testing.T) {
+	engine, _ := NewEngine(EnginePCRE)
+	pattern, err := engine.Compile("^(?!.*_test\\.go$).*\\.go$")
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	if !pattern.Match([]byte("main.go")) {
+		t.Errorf("Match(main.go) = false, want true")
+	}
+	if pattern.Match([]byte("main_test.go")) {
+		t.Errorf("Match(main_test.go) = true, want false")
+	}
+}
+
+func TestPCREEngineMaxSteps(t *testing.T) {
+	// (a+)+$ against a run of a's that ends in something else backtracks exponentially.
+	line := []byte(strings.Repeat("a", 40) + "!")
+
+	engine, _ := NewEngine(EnginePCRE)
+	pattern, err := engine.CompileWithOptions("(a+)+$", CompileOptions{MaxSteps: 1000})
+	if err != nil {
+		t.Fatalf("CompileWithOptions() error = %v", err)
+	}
+
+	start := time.Now()
+	matched, err := pattern.(ContextPattern).MatchContext(context.Background(), line)
+	if matched || !errors.Is(err, ErrMatchBudgetExceeded) {
+		t.Errorf("MatchContext() = %v, %v, want false, ErrMatchBudgetExceeded", matched, err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("MatchContext() took %v, want it to give up early", elapsed)
+	}
+
+	if pattern.Match(line) {
+		t.Errorf("Match() = true, want false once the budget runs out")
+	}
+	if !pattern.Match([]byte("aaa")) {
+		t.Errorf("Match(aaa) = false, want true within the budget")
+	}
+}
+
+func TestEngineCompileWithOptions(t *testing.T) {
+	opts := CompileOptions{CaseInsensitive: true}
+	for _, name := range Engines() {
+		engine, _ := NewEngine(name)
+		pattern, err := engine.CompileWithOptions("\\.go$", opts)
+		if err != nil {
+			t.Fatalf("%s: CompileWithOptions() error = %v", name, err)
+		}
+		if !pattern.Match([]byte("MAIN.GO")) {
+			t.Errorf("%s: Match(MAIN.GO) = false, want true", name)
+		}
+	}
+}
+
+func TestEngineCompileErrors(t *testing.T) {
+	for _, name := range Engines() {
+		engine, _ := NewEngine(name)
+		if _, err := engine.Compile("(abc"); err == nil {
+			t.Errorf("%s: Compile(\"(abc\") error = nil, want an error", name)
+		}
+	}
+}
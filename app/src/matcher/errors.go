@@ -0,0 +1,47 @@
+package matcher
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SyntaxError is returned by Compile when a pattern is malformed.
+type SyntaxError struct {
+	Pattern string // the pattern being compiled
+	Offset  int    // byte offset in Pattern where the problem was found
+	Problem string // description of the problem, e.g. "missing closing ]"
+}
+
+// Error returns the problem and its offset followed by the caret excerpt.
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%s at offset %d\n%s", e.Problem, e.Offset, e.Excerpt())
+}
+
+// Excerpt returns the pattern on one line and a caret pointing at the offending
+// character on the next, both indented by a tab:
+//
+//	ab[c
+//	  ^
+func (e *SyntaxError) Excerpt() string {
+	offset := min(max(e.Offset, 0), len(e.Pattern))
+
+	var caret strings.Builder
+	for _, c := range e.Pattern[:offset] {
+		if c == '\t' {
+			caret.WriteByte('\t')
+		} else {
+			caret.WriteByte(' ')
+		}
+	}
+
+	return "\t" + e.Pattern + "\n\t" + caret.String() + "^"
+}
+
+// errorf returns a SyntaxError for the pattern being parsed at the given offset.
+func (p *parser) errorf(offset int, format string, args ...any) *SyntaxError {
+	return &SyntaxError{
+		Pattern: p.pattern,
+		Offset:  offset,
+		Problem: fmt.Sprintf(format, args...),
+	}
+}
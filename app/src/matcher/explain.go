@@ -0,0 +1,129 @@
+package matcher
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Describe returns a one-line, human-readable description of the node itself,
+// without its children, such as `repeat + (1 or more, greedy)` or `class [a-z]`.
+func (n *Node) Describe() string {
+	switch n.Kind {
+	case NodeEmpty:
+		return "empty"
+	case NodeLiteral:
+		return "literal " + n.charString()
+	case NodeAnyChar:
+		return "any character (?s)."
+	case NodeAnyCharNotNL:
+		return "any character except newline ."
+	case NodeEscape:
+		return "escape " + n.charString()
+	case NodeCharClass:
+		return "class " + n.charString()
+	case NodeBeginLine:
+		return "start of line (?m)^"
+	case NodeEndLine:
+		return "end of line (?m)$"
+	case NodeBeginText:
+		return "start of input ^"
+	case NodeEndText:
+		return "end of input $"
+	case NodeWordBoundary:
+		return "word boundary \\b"
+	case NodeNotWordBoundary:
+		return "not a word boundary \\B"
+	case NodeRepeat:
+		return "repeat " + n.describeRepeat()
+	case NodeConcat:
+		return "sequence"
+	case NodeAlternate:
+		return fmt.Sprintf("alternation of %d", len(n.Children))
+	case NodeCapture:
+		if n.Name != "" {
+			return fmt.Sprintf("capture group %d %q", n.Index, n.Name)
+		}
+		return fmt.Sprintf("capture group %d", n.Index)
+	case NodeBackref:
+		if n.Fold {
+			return fmt.Sprintf("backreference (?i)\\%d", n.Index)
+		}
+		return fmt.Sprintf("backreference \\%d", n.Index)
+	case NodeLook:
+		return n.describeLook()
+	default:
+		return "unknown"
+	}
+}
+
+// describeRepeat returns the quantifier of a repeat node followed by its bounds and
+// mode in words, as in `{2,} (2 or more, lazy)`.
+func (n *Node) describeRepeat() string {
+	var quantifier, bounds string
+	switch {
+	case n.Min == 0 && n.Max == -1:
+		quantifier, bounds = "*", "0 or more"
+	case n.Min == 1 && n.Max == -1:
+		quantifier, bounds = "+", "1 or more"
+	case n.Min == 0 && n.Max == 1:
+		quantifier, bounds = "?", "0 or 1"
+	case n.Max == -1:
+		quantifier, bounds = fmt.Sprintf("{%d,}", n.Min), fmt.Sprintf("%d or more", n.Min)
+	case n.Min == n.Max:
+		quantifier, bounds = fmt.Sprintf("{%d}", n.Min), fmt.Sprintf("exactly %d", n.Min)
+	default:
+		quantifier, bounds = fmt.Sprintf("{%d,%d}", n.Min, n.Max), fmt.Sprintf("%d to %d", n.Min, n.Max)
+	}
+
+	switch n.Mode {
+	case RepeatLazy:
+		return quantifier + "? (" + bounds + ", lazy)"
+	case RepeatPossessive:
+		return quantifier + "+ (" + bounds + ", possessive)"
+	default:
+		return quantifier + " (" + bounds + ", greedy)"
+	}
+}
+
+// describeLook names the kind of a lookaround node.
+func (n *Node) describeLook() string {
+	switch n.Look {
+	case LookAhead:
+		return "lookahead (?=...)"
+	case LookAheadNot:
+		return "negative lookahead (?!...)"
+	case LookBehind:
+		return "lookbehind (?<=...)"
+	default:
+		return "negative lookbehind (?<!...)"
+	}
+}
+
+// Tree returns an indented tree of the node and everything below it, one node per
+// line, as in:
+//
+//	sequence
+//	├── start of input ^
+//	├── repeat + (1 or more, greedy)
+//	│   └── escape \d
+//	└── end of input $
+func (n *Node) Tree() string {
+	var sb strings.Builder
+	sb.WriteString(n.Describe())
+	sb.WriteByte('\n')
+	n.writeChildren(&sb, "")
+	return sb.String()
+}
+
+// writeChildren writes the subtrees of the node's children, each line starting with indent.
+func (n *Node) writeChildren(sb *strings.Builder, indent string) {
+	for i, child := range n.Children {
+		branch, next := "├── ", "│   "
+		if i == len(n.Children)-1 {
+			branch, next = "└── ", "    "
+		}
+
+		sb.WriteString(indent + branch + child.Describe() + "\n")
+		child.writeChildren(sb, indent+next)
+	}
+}
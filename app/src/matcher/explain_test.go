@@ -0,0 +1,59 @@
+package matcher
+
+import "testing"
+
+func TestNodeTree(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		want    string
+	}{
+		{
+			name:    "literal",
+			pattern: "a",
+			want:    "literal 'a'\n",
+		},
+		{
+			name:    "anchored repetition",
+			pattern: "^\\d+$",
+			want: "sequence\n" +
+				"├── start of input ^\n" +
+				"├── repeat + (1 or more, greedy)\n" +
+				"│   └── escape \\d\n" +
+				"└── end of input $\n",
+		},
+		{
+			name:    "groups and alternation",
+			pattern: "(?P<ext>go|rs){2,}?[^.]",
+			want: "sequence\n" +
+				"├── repeat {2,}? (2 or more, lazy)\n" +
+				"│   └── capture group 1 \"ext\"\n" +
+				"│       └── alternation of 2\n" +
+				"│           ├── sequence\n" +
+				"│           │   ├── literal 'g'\n" +
+				"│           │   └── literal 'o'\n" +
+				"│           └── sequence\n" +
+				"│               ├── literal 'r'\n" +
+				"│               └── literal 's'\n" +
+				"└── class [^.]\n",
+		},
+		{
+			name:    "lookaround and backreference",
+			pattern: "(?i)(a)(?!\\1)",
+			want: "sequence\n" +
+				"├── empty\n" +
+				"├── capture group 1\n" +
+				"│   └── literal (?i)'a'\n" +
+				"└── negative lookahead (?!...)\n" +
+				"    └── backreference (?i)\\1\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MustCompile(tt.pattern).Syntax().Tree(); got != tt.want {
+				t.Errorf("Tree() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package matcher
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// fuzzAlphabet is the set of characters fuzzed lines are made of. It is ASCII only,
+// since \w, \d and \b are Unicode-aware here but ASCII-only in regexp.
+const fuzzAlphabet = "abcA1 _-.\n"
+
+// patternGen builds a pattern in the syntax shared with regexp, taking its choices
+// from fuzzer-provided bytes. Once the bytes run out every choice is 0, which always
+// leads to the pattern being finished.
+type patternGen struct {
+	data []byte
+	pos  int
+	sb   strings.Builder
+	ncap int
+}
+
+// generatePattern returns the pattern described by data.
+func generatePattern(data []byte) string {
+	g := &patternGen{data: data}
+	g.alternation(0)
+	return g.sb.String()
+}
+
+// choose returns a choice in [0, n).
+func (g *patternGen) choose(n int) int {
+	if g.pos >= len(g.data) {
+		return 0
+	}
+	b := g.data[g.pos]
+	g.pos++
+	return int(b) % n
+}
+
+func (g *patternGen) alternation(depth int) {
+	g.concat(depth)
+	for g.choose(4) == 3 {
+		g.sb.WriteByte('|')
+		g.concat(depth)
+	}
+}
+
+func (g *patternGen) concat(depth int) {
+	for n := g.choose(5); n > 0; n-- {
+		if g.atom(depth) {
+			g.quantifier()
+		}
+	}
+}
+
+// fuzzAtoms are the atoms that need no recursion. The ones after the first
+// fuzzAssertions entries are zero-width and never quantified.
+var fuzzAtoms = []string{
+	"a", "b", "c", "A", "1", " ", "_", "-", "\\.", "\\n",
+	".", "[abc]", "[^a]", "[a-c]", "[^\\n ]", "[[:alpha:]]", "[[:digit:]_]", "\\d", "\\D", "\\w", "\\W", "\\s", "\\S",
+	"^", "$", "\\b", "\\B",
+}
+
+const fuzzAssertions = 4
+
+// atom writes a single atom and reports whether it may be quantified.
+func (g *patternGen) atom(depth int) bool {
+	choices := len(fuzzAtoms)
+	if depth < 3 {
+		choices += 4
+	}
+
+	switch c := g.choose(choices); {
+	case c < len(fuzzAtoms):
+		g.sb.WriteString(fuzzAtoms[c])
+		return c < len(fuzzAtoms)-fuzzAssertions
+
+	case c == len(fuzzAtoms):
+		g.ncap++
+		g.sb.WriteByte('(')
+	case c == len(fuzzAtoms)+1:
+		g.sb.WriteString("(?:")
+	case c == len(fuzzAtoms)+2:
+		g.ncap++
+		fmt.Fprintf(&g.sb, "(?P<g%d>", g.ncap)
+	default:
+		g.sb.WriteString([]string{"(?i:", "(?m:", "(?s:", "(?i)", "(?-i:"}[g.choose(5)])
+		if g.sb.String()[g.sb.Len()-1] == ')' {
+			return false
+		}
+	}
+
+	g.alternation(depth + 1)
+	g.sb.WriteByte(')')
+	return true
+}
+
+func (g *patternGen) quantifier() {
+	switch g.choose(12) {
+	case 6:
+		g.sb.WriteByte('*')
+	case 7:
+		g.sb.WriteByte('+')
+	case 8:
+		g.sb.WriteByte('?')
+	case 9:
+		fmt.Fprintf(&g.sb, "{%d}", g.choose(4))
+	case 10:
+		fmt.Fprintf(&g.sb, "{%d,}", g.choose(4))
+	case 11:
+		min := g.choose(3)
+		fmt.Fprintf(&g.sb, "{%d,%d}", min, min+g.choose(3))
+	default:
+		return
+	}
+
+	if g.choose(3) == 0 {
+		g.sb.WriteByte('?')
+	}
+}
+
+// fuzzLine maps arbitrary bytes onto fuzzAlphabet.
+func fuzzLine(data []byte) []byte {
+	line := make([]byte, len(data))
+	for i, b := range data {
+		line[i] = fuzzAlphabet[int(b)%len(fuzzAlphabet)]
+	}
+	return line
+}
+
+// FuzzDifferential checks that patterns in the syntax shared with regexp find the same
+// matches, submatches and successive matches as regexp does.
+func FuzzDifferential(f *testing.F) {
+	f.Add([]byte{4, 10, 6}, []byte("aab"))
+	f.Add([]byte{3, 27, 2, 0, 3, 1, 0, 7}, []byte("ab a"))
+	f.Add([]byte{2, 11, 6, 0, 1, 30, 3, 1, 16, 7}, []byte("A1-b c"))
+
+	f.Fuzz(func(t *testing.T, choices []byte, lineData []byte) {
+		pattern := generatePattern(choices)
+		line := fuzzLine(lineData)
+
+		want, err := regexp.Compile(pattern)
+		if err != nil {
+			t.Skip()
+		}
+		re, err := Compile(pattern)
+		if err != nil {
+			t.Fatalf("Compile(%q) error = %v, but regexp accepts it", pattern, err)
+		}
+
+		if got, want := re.Match(line), want.Match(line); got != want {
+			t.Errorf("Compile(%q).Match(%q) = %v, want %v", pattern, line, got, want)
+		}
+		if got, want := re.FindSubmatchIndex(line), want.FindSubmatchIndex(line); !reflect.DeepEqual(got, want) {
+			t.Errorf("Compile(%q).FindSubmatchIndex(%q) = %v, want %v", pattern, line, got, want)
+		}
+		if got, want := re.FindAllIndex(line, -1), want.FindAllIndex(line, -1); !reflect.DeepEqual(got, want) {
+			t.Errorf("Compile(%q).FindAllIndex(%q) = %v, want %v", pattern, line, got, want)
+		}
+	})
+}
+
+// FuzzCompile checks that no pattern, however malformed, makes Compile or matching panic.
+func FuzzCompile(f *testing.F) {
+	for _, pattern := range []string{"a+b", "(?P<x>a)\\1", "[[:^alpha:]]", "(?<=a)b", "x{2,", "\\p{Greek}+", "(?i:é)"} {
+		f.Add(pattern, []byte("abc aé"))
+	}
+
+	f.Fuzz(func(t *testing.T, pattern string, line []byte) {
+		re, err := CompileWithOptions(pattern, CompileOptions{MaxSteps: 100000})
+		if err != nil {
+			return
+		}
+		re.Match(line)
+		re.FindSubmatchIndex(line)
+		re.FindAllIndex(line, -1)
+	})
+}
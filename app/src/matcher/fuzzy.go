@@ -0,0 +1,190 @@
+package matcher
+
+import (
+	"unicode"
+	"unicode/utf8"
+)
+
+// Scores used to rank fuzzy matches, in the spirit of fzf. Every matched character
+// scores fuzzyScoreMatch plus the bonus of its position, and every run of skipped
+// characters between two matched ones costs a penalty growing with its length.
+const (
+	fuzzyScoreMatch        = 16
+	fuzzyGapStart          = -3 // first character skipped between two matched ones
+	fuzzyGapExtension      = -1 // every further character skipped
+	fuzzyBonusSeparator    = 9  // character right after a path separator
+	fuzzyBonusBoundary     = 8  // character at the start of the text or after '_', '-', '.' or a space
+	fuzzyBonusCamel        = 7  // upper case letter after a lower case one, or digit after a letter
+	fuzzyBonusConsecutive  = 4  // least bonus of a character right after another matched one
+	fuzzyFirstCharMultiple = 2  // the first query character's bonus counts this many times
+)
+
+// Fuzzy is a compiled fuzzy query. A text matches it if the characters of the query
+// appear in the text in order, not necessarily next to each other, as "rgxgo" does in
+// "regexp.go". A Fuzzy is safe for concurrent use.
+type Fuzzy struct {
+	query []rune
+	fold  bool
+}
+
+// FuzzyMatch describes how a query matched a text.
+type FuzzyMatch struct {
+	Score     int   // higher for matches in runs, at word starts and after path separators
+	Positions []int // byte offsets in the text of the matched characters, in increasing order
+}
+
+// CompileFuzzy returns a Fuzzy for the query. Of the options, only CaseInsensitive has
+// any effect.
+func CompileFuzzy(query string, opts CompileOptions) *Fuzzy {
+	f := &Fuzzy{query: []rune(query), fold: opts.CaseInsensitive}
+	if f.fold {
+		for i, r := range f.query {
+			f.query[i] = unicode.ToLower(r)
+		}
+	}
+	return f
+}
+
+// String returns the source query.
+func (f *Fuzzy) String() string {
+	return string(f.query)
+}
+
+// Match reports whether the characters of the query appear in the text in order.
+func (f *Fuzzy) Match(text []byte) bool {
+	i := 0
+	for pos := 0; pos < len(text) && i < len(f.query); {
+		char, width := decodeRune(text, pos)
+		if f.equal(char, f.query[i]) {
+			i++
+		}
+		pos += width
+	}
+	return i == len(f.query)
+}
+
+// Find returns the best-scoring way the query matches the text, or false if it does not.
+// Among all ways of picking the query characters out of the text, the one with the
+// highest score wins.
+//
+// Parameters:
+// - text: The text to match, usually a file name or path.
+//
+// Returns:
+// - FuzzyMatch: The score and positions of the best match.
+// - bool: True if the query matches the text.
+func (f *Fuzzy) Find(text []byte) (FuzzyMatch, bool) {
+	if !f.Match(text) {
+		return FuzzyMatch{}, false
+	}
+	if len(f.query) == 0 {
+		return FuzzyMatch{}, true
+	}
+
+	var chars []rune
+	var offsets []int
+	for pos := 0; pos < len(text); {
+		char, width := decodeRune(text, pos)
+		chars = append(chars, char)
+		offsets = append(offsets, pos)
+		pos += width
+	}
+	bonuses := fuzzyBonuses(chars)
+
+	// score[i][j] is the best score of matching query[:i+1] with query[i] at chars[j],
+	// or noScore; from[i][j] is where query[i-1] was matched on that path, and run[i][j]
+	// the bonus of the first character of the run of consecutive matches ending there.
+	const noScore = -1 << 30
+	n, m := len(chars), len(f.query)
+	score := make([][]int, m)
+	from := make([][]int, m)
+	run := make([][]int, m)
+
+	for i := range f.query {
+		score[i] = make([]int, n)
+		from[i] = make([]int, n)
+		run[i] = make([]int, n)
+
+		gapBest, gapFrom := noScore, -1
+		for j := range chars {
+			score[i][j] = noScore
+
+			if i > 0 && j >= 2 && score[i-1][j-2] != noScore {
+				if extended := gapBest + fuzzyGapExtension; gapBest == noScore || score[i-1][j-2]+fuzzyGapStart >= extended {
+					gapBest, gapFrom = score[i-1][j-2]+fuzzyGapStart, j-2
+				} else {
+					gapBest = extended
+				}
+			} else if gapBest != noScore {
+				gapBest += fuzzyGapExtension
+			}
+
+			if !f.equal(chars[j], f.query[i]) {
+				continue
+			}
+
+			if i == 0 {
+				score[i][j] = fuzzyScoreMatch + bonuses[j]*fuzzyFirstCharMultiple
+				from[i][j], run[i][j] = -1, bonuses[j]
+				continue
+			}
+
+			if gapBest != noScore {
+				score[i][j] = gapBest + fuzzyScoreMatch + bonuses[j]
+				from[i][j], run[i][j] = gapFrom, bonuses[j]
+			}
+
+			if j >= 1 && score[i-1][j-1] != noScore {
+				bonus := max(bonuses[j], run[i-1][j-1], fuzzyBonusConsecutive)
+				if consecutive := score[i-1][j-1] + fuzzyScoreMatch + bonus; consecutive >= score[i][j] {
+					score[i][j] = consecutive
+					from[i][j], run[i][j] = j-1, max(run[i-1][j-1], bonuses[j])
+				}
+			}
+		}
+	}
+
+	best := -1
+	for j := range chars {
+		if score[m-1][j] != noScore && (best == -1 || score[m-1][j] > score[m-1][best]) {
+			best = j
+		}
+	}
+
+	positions := make([]int, m)
+	for i, j := m-1, best; i >= 0; i-- {
+		positions[i] = offsets[j]
+		j = from[i][j]
+	}
+	return FuzzyMatch{Score: score[m-1][best], Positions: positions}, true
+}
+
+// equal reports whether a text character matches a query character.
+func (f *Fuzzy) equal(char, query rune) bool {
+	if f.fold {
+		return unicode.ToLower(char) == query
+	}
+	return char == query
+}
+
+// fuzzyBonuses returns the bonus of matching each character of the text, based on the
+// character before it.
+func fuzzyBonuses(chars []rune) []int {
+	bonuses := make([]int, len(chars))
+	prev := utf8.RuneError
+	for j, char := range chars {
+		switch {
+		case j == 0:
+			bonuses[j] = fuzzyBonusBoundary
+		case prev == '/' || prev == '\\':
+			bonuses[j] = fuzzyBonusSeparator
+		case prev == '_' || prev == '-' || prev == '.' || unicode.IsSpace(prev):
+			bonuses[j] = fuzzyBonusBoundary
+		case unicode.IsLower(prev) && unicode.IsUpper(char),
+			unicode.IsLetter(prev) && unicode.IsDigit(char):
+			bonuses[j] = fuzzyBonusCamel
+		}
+		prev = char
+	}
+	return bonuses
+}
@@ -0,0 +1,87 @@
+package matcher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		query string
+		text  string
+		fold  bool
+		want  bool
+	}{
+		{"rgxgo", "regexp.go", false, true},
+		{"", "anything", false, true},
+		{"go", "og", false, false},
+		{"abc", "ab", false, false},
+		{"RGX", "regexp.go", false, false},
+		{"RGX", "regexp.go", true, true},
+		{"żó", "żółw.txt", false, true},
+	}
+
+	for _, tt := range tests {
+		f := CompileFuzzy(tt.query, CompileOptions{CaseInsensitive: tt.fold})
+		if got := f.Match([]byte(tt.text)); got != tt.want {
+			t.Errorf("CompileFuzzy(%q).Match(%q) = %v, want %v", tt.query, tt.text, got, tt.want)
+		}
+		if _, got := f.Find([]byte(tt.text)); got != tt.want {
+			t.Errorf("CompileFuzzy(%q).Find(%q) matched = %v, want %v", tt.query, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFuzzyFindPositions(t *testing.T) {
+	tests := []struct {
+		query string
+		text  string
+		want  []int
+	}{
+		{"go", "regexp.go", []int{7, 8}},
+		{"fb", "foo_bar", []int{0, 4}},
+		{"mfgo", "src/matcher/fuzzy.go", []int{4, 12, 18, 19}},
+		{"ab", "xaxab", []int{3, 4}},
+		{"ł", "żółw", []int{4}},
+	}
+
+	for _, tt := range tests {
+		m, ok := CompileFuzzy(tt.query, CompileOptions{}).Find([]byte(tt.text))
+		if !ok {
+			t.Errorf("CompileFuzzy(%q).Find(%q) did not match", tt.query, tt.text)
+			continue
+		}
+		if !reflect.DeepEqual(m.Positions, tt.want) {
+			t.Errorf("CompileFuzzy(%q).Find(%q).Positions = %v, want %v", tt.query, tt.text, m.Positions, tt.want)
+		}
+	}
+}
+
+func TestFuzzyFindRanking(t *testing.T) {
+	tests := []struct {
+		name          string
+		query         string
+		better, worse string
+	}{
+		{"consecutive run", "file", "profile.go", "fxixlxe.go"},
+		{"word starts", "fz", "fuzzy_zone", "fizz"},
+		{"after path separator", "mg", "src/matcher/glob.go", "src/matcher/ignore"},
+		{"camel case", "sm", "setMatch.go", "sumo.go"},
+		{"shorter gap", "ab", "a-b", "a---b"},
+		{"earlier word start", "go", "go.mod", "algo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f := CompileFuzzy(tt.query, CompileOptions{CaseInsensitive: true})
+			better, ok1 := f.Find([]byte(tt.better))
+			worse, ok2 := f.Find([]byte(tt.worse))
+			if !ok1 || !ok2 {
+				t.Fatalf("Find() matched %q = %v, %q = %v, want both", tt.better, ok1, tt.worse, ok2)
+			}
+			if better.Score <= worse.Score {
+				t.Errorf("score of %q = %d, want more than %d for %q", tt.better, better.Score, worse.Score, tt.worse)
+			}
+		})
+	}
+}
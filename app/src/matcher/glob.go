@@ -0,0 +1,208 @@
+package matcher
+
+import "strings"
+
+// Characters with a special meaning in globs.
+const (
+	GlobSeparator  = '/'
+	GlobAnyChar    = '?'
+	GlobAnyString  = '*'
+	GlobNegateSet  = '!'
+	GlobAltsOpen   = '{'
+	GlobAltsClose  = '}'
+	GlobAltsSplit  = ','
+	GlobEscapeChar = '\\'
+)
+
+// CompileGlob parses a glob and returns a Regexp matching the strings the glob
+// describes. A glob always matches a whole string, such as a file name or a path:
+//
+//	?       any single character other than '/'
+//	*       any run of characters other than '/'
+//	**      any run of characters, '/' included; **/ also matches no directory at all
+//	[...]   a bracket expression, as in a regular expression, also negated by [!...]
+//	{a,b}   either of the comma-separated globs, which may nest
+//	\c      the character c itself
+//
+// The glob is compiled into the same kind of program as a regular expression, so the
+// Regexp it returns has every method a compiled regular expression has.
+//
+// Parameters:
+// - pattern: The glob to be compiled.
+//
+// Returns:
+// - *Regexp: The compiled glob.
+// - error: A *SyntaxError describing the problem if the glob is malformed.
+func CompileGlob(pattern string) (*Regexp, error) {
+	return CompileGlobWithOptions(pattern, CompileOptions{})
+}
+
+// CompileGlobWithOptions is like CompileGlob but compiles the glob with the given
+// options. Only CaseInsensitive and MaxSteps have any effect on a glob.
+func CompileGlobWithOptions(pattern string, opts CompileOptions) (*Regexp, error) {
+	p := &parser{pattern: pattern, names: []string{""}, flags: opts, glob: true}
+
+	body, err := p.parseGlob(-1)
+	if err != nil {
+		return nil, err
+	}
+
+	root := &Node{Kind: NodeConcat, Children: []*Node{{Kind: NodeBeginText}, body, {Kind: NodeEndText}}}
+	return newRegexp(pattern, root, p, opts)
+}
+
+// MustCompileGlob is like CompileGlob but panics if the glob cannot be parsed.
+func MustCompileGlob(pattern string) *Regexp {
+	re, err := CompileGlob(pattern)
+	if err != nil {
+		panic("matcher: CompileGlob(" + pattern + "): " + err.Error())
+	}
+	return re
+}
+
+// LooksLikeGlob guesses whether a pattern was meant as a glob rather than a regular
+// expression. It does if it is not a valid regular expression, or if it uses '*' or
+// {a,b} alternatives without any syntax only regular expressions have, such as ".*",
+// anchors, groups or escapes.
+func LooksLikeGlob(pattern string) bool {
+	if _, err := Compile(pattern); err != nil {
+		return true
+	}
+
+	if strings.Contains(pattern, ".*") || strings.Contains(pattern, ".+") ||
+		strings.ContainsAny(pattern, "^$()|+\\") {
+		return false
+	}
+
+	return strings.ContainsRune(pattern, GlobAnyString) || hasGlobAlternatives(pattern)
+}
+
+// hasGlobAlternatives reports whether the pattern has braces holding anything other
+// than a repetition count such as {2} or {2,5}.
+func hasGlobAlternatives(pattern string) bool {
+	for {
+		open := strings.IndexByte(pattern, GlobAltsOpen)
+		if open == -1 {
+			return false
+		}
+		end := strings.IndexByte(pattern[open:], GlobAltsClose)
+		if end == -1 {
+			return false
+		}
+
+		inner := pattern[open+1 : open+end]
+		if strings.ContainsRune(inner, GlobAltsSplit) && strings.Trim(inner, "0123456789,") != "" {
+			return true
+		}
+		pattern = pattern[open+end:]
+	}
+}
+
+// parseGlob parses a sequence of glob elements up to the end of the pattern or, inside
+// the alternatives opened at offset altsStart, up to the next ',' or '}'.
+func (p *parser) parseGlob(altsStart int) (*Node, error) {
+	concat := &Node{Kind: NodeConcat}
+
+	for {
+		if p.done() {
+			if altsStart >= 0 {
+				return nil, p.errorf(altsStart, "missing closing } for alternatives")
+			}
+			break
+		}
+
+		c := p.peek()
+		if altsStart >= 0 && (c == GlobAltsSplit || c == GlobAltsClose) {
+			break
+		}
+
+		node, err := p.parseGlobElement()
+		if err != nil {
+			return nil, err
+		}
+		concat.Children = append(concat.Children, node)
+	}
+
+	if len(concat.Children) == 1 {
+		return concat.Children[0], nil
+	}
+	return concat, nil
+}
+
+// parseGlobElement parses a single glob element starting at the current position.
+func (p *parser) parseGlobElement() (*Node, error) {
+	switch p.peek() {
+	case GlobAnyString:
+		p.pos++
+		if p.done() || p.peek() != GlobAnyString {
+			return globRepeat(notSeparator()), nil
+		}
+
+		p.pos++
+		if p.done() || p.peek() != GlobSeparator {
+			return globRepeat(&Node{Kind: NodeAnyChar}), nil
+		}
+
+		p.pos++
+		dirs := &Node{Kind: NodeConcat, Children: []*Node{globRepeat(&Node{Kind: NodeAnyChar}), p.literal(GlobSeparator)}}
+		return &Node{Kind: NodeRepeat, Min: 0, Max: 1, Children: []*Node{dirs}}, nil
+
+	case GlobAnyChar:
+		p.pos++
+		return notSeparator(), nil
+
+	case LeftBracket:
+		return p.parseCharClass()
+
+	case GlobAltsOpen:
+		return p.parseGlobAlternatives()
+
+	case GlobEscapeChar:
+		if p.pos+1 == len(p.pattern) {
+			return nil, p.errorf(p.pos, "trailing backslash at end of glob")
+		}
+		p.pos++
+		return p.literal(p.nextRune()), nil
+
+	default:
+		return p.literal(p.nextRune()), nil
+	}
+}
+
+// parseGlobAlternatives parses comma-separated globs between the current '{' and its
+// matching '}'.
+func (p *parser) parseGlobAlternatives() (*Node, error) {
+	start := p.pos
+	p.pos++
+
+	alt := &Node{Kind: NodeAlternate}
+	for {
+		node, err := p.parseGlob(start)
+		if err != nil {
+			return nil, err
+		}
+		alt.Children = append(alt.Children, node)
+
+		if p.peek() == GlobAltsClose {
+			p.pos++
+			break
+		}
+		p.pos++
+	}
+
+	if len(alt.Children) == 1 {
+		return alt.Children[0], nil
+	}
+	return alt, nil
+}
+
+// notSeparator returns a node matching any single character other than '/'.
+func notSeparator() *Node {
+	class := &CharClass{Ranges: []RuneRange{{GlobSeparator, GlobSeparator}}, Negated: true}
+	return &Node{Kind: NodeCharClass, Class: class, Text: "[^/]"}
+}
+
+// globRepeat returns a node matching any number of repetitions of the node.
+func globRepeat(n *Node) *Node {
+	return &Node{Kind: NodeRepeat, Min: 0, Max: -1, Children: []*Node{n}}
+}
@@ -0,0 +1,94 @@
+package matcher
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCompileGlob(t *testing.T) {
+	tests := []struct {
+		glob  string
+		name  string
+		want  bool
+		iCase bool
+	}{
+		{glob: "*.go", name: "main.go", want: true},
+		{glob: "*.go", name: "main.go.bak", want: false},
+		{glob: "*.go", name: "cmd/main.go", want: false},
+		{glob: "main.?o", name: "main.go", want: true},
+		{glob: "main.?o", name: "main.o", want: false},
+		{glob: "[a-c]*", name: "build.sh", want: true},
+		{glob: "[!a-c]*", name: "build.sh", want: false},
+		{glob: "[^a-c]*", name: "deploy.sh", want: true},
+		{glob: "*.{go,mod}", name: "go.mod", want: true},
+		{glob: "*.{go,mod}", name: "go.sum", want: false},
+		{glob: "{*_test,doc}.go", name: "set_test.go", want: true},
+		{glob: "{a,{b,c}d}", name: "cd", want: true},
+		{glob: "src/**/*.go", name: "src/matcher/regexp.go", want: true},
+		{glob: "src/**/*.go", name: "src/main.go", want: true},
+		{glob: "src/**/*.go", name: "app/src/main.go", want: false},
+		{glob: "**.md", name: "docs/a/README.md", want: true},
+		{glob: "\\*.go", name: "*.go", want: true},
+		{glob: "\\*.go", name: "a.go", want: false},
+		{glob: "a+b(c)", name: "a+b(c)", want: true},
+		{glob: "README*", name: "readme.md", want: true, iCase: true},
+		{glob: "README*", name: "readme.md", want: false},
+		{glob: "", name: "", want: true},
+	}
+
+	for _, tt := range tests {
+		re, err := CompileGlobWithOptions(tt.glob, CompileOptions{CaseInsensitive: tt.iCase})
+		if err != nil {
+			t.Fatalf("CompileGlob(%q) error = %v", tt.glob, err)
+		}
+		if got := re.Match([]byte(tt.name)); got != tt.want {
+			t.Errorf("CompileGlob(%q).Match(%q) = %v, want %v", tt.glob, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCompileGlobError(t *testing.T) {
+	tests := []struct {
+		glob       string
+		wantOffset int
+	}{
+		{glob: "*.{go,mod", wantOffset: 2},
+		{glob: "[abc", wantOffset: 0},
+		{glob: "a\\", wantOffset: 1},
+	}
+
+	for _, tt := range tests {
+		_, err := CompileGlob(tt.glob)
+
+		var syntaxErr *SyntaxError
+		if !errors.As(err, &syntaxErr) {
+			t.Fatalf("CompileGlob(%q) error = %v, want a *SyntaxError", tt.glob, err)
+		}
+		if syntaxErr.Offset != tt.wantOffset {
+			t.Errorf("CompileGlob(%q) error offset = %d, want %d", tt.glob, syntaxErr.Offset, tt.wantOffset)
+		}
+	}
+}
+
+func TestLooksLikeGlob(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    bool
+	}{
+		{pattern: "*.go", want: true},
+		{pattern: "main*", want: true},
+		{pattern: "*.{yml,yaml}", want: true},
+		{pattern: "src/**/*.go", want: true},
+		{pattern: "main", want: false},
+		{pattern: "^main.*\\.go$", want: false},
+		{pattern: "a{2,3}", want: false},
+		{pattern: "(foo|bar)", want: false},
+		{pattern: "_test\\.go$", want: false},
+	}
+
+	for _, tt := range tests {
+		if got := LooksLikeGlob(tt.pattern); got != tt.want {
+			t.Errorf("LooksLikeGlob(%q) = %v, want %v", tt.pattern, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,224 @@
+package matcher
+
+import "unicode/utf8"
+
+const (
+	maxLiteralSet = 16  // strings kept in a literal set before giving up on it
+	maxLiteralLen = 256 // bytes kept in a single literal string
+)
+
+// literalSet describes the literal text a node matches, as far as it can be known
+// without running the pattern.
+type literalSet struct {
+	exact    []string // the node matches exactly one of these strings; meaningless unless isExact
+	isExact  bool
+	required []string // every match of the node contains one of these strings; nil if unknown
+}
+
+// literals analyses a node for the literal strings its matches must contain. Case
+// insensitive literals, classes, dots and backreferences are treated as unknown text.
+func literals(n *Node) literalSet {
+	switch n.Kind {
+	case NodeLiteral:
+		if n.Fold || n.Char == utf8.RuneError {
+			return literalSet{}
+		}
+		return exactSet([]string{string(n.Char)})
+
+	case NodeEmpty, NodeBeginLine, NodeEndLine, NodeBeginText, NodeEndText,
+		NodeWordBoundary, NodeNotWordBoundary, NodeLook:
+		return exactSet([]string{""})
+
+	case NodeCapture:
+		return literals(n.Children[0])
+
+	case NodeConcat:
+		return concatLiterals(n.Children)
+
+	case NodeAlternate:
+		return alternateLiterals(n.Children)
+
+	case NodeRepeat:
+		if n.Min == 0 {
+			return literalSet{}
+		}
+		child := literals(n.Children[0])
+		if !child.isExact {
+			return literalSet{required: child.required}
+		}
+		if n.Min == n.Max {
+			repeated := []string{""}
+			for i := 0; i < n.Min; i++ {
+				var ok bool
+				if repeated, ok = crossLiterals(repeated, child.exact); !ok {
+					return literalSet{required: child.required}
+				}
+			}
+			return exactSet(repeated)
+		}
+		return literalSet{required: child.required}
+
+	default:
+		return literalSet{}
+	}
+}
+
+// concatLiterals joins runs of exactly known children into longer strings and keeps
+// the best requirement found along the way.
+func concatLiterals(children []*Node) literalSet {
+	run := []string{""}
+	allExact := true
+	var best []string
+
+	for _, child := range children {
+		set := literals(child)
+		if set.isExact {
+			if joined, ok := crossLiterals(run, set.exact); ok {
+				run = joined
+				continue
+			}
+			best = betterLiterals(best, usableLiterals(run))
+			run = set.exact
+			allExact = false
+			continue
+		}
+
+		allExact = false
+		best = betterLiterals(best, usableLiterals(run))
+		best = betterLiterals(best, set.required)
+		run = []string{""}
+	}
+
+	if allExact {
+		return exactSet(run)
+	}
+	return literalSet{required: betterLiterals(best, usableLiterals(run))}
+}
+
+// alternateLiterals merges the sets of the alternatives. A requirement only survives
+// if every alternative has one.
+func alternateLiterals(children []*Node) literalSet {
+	var exact, required []string
+	allExact, allRequired := true, true
+
+	for _, child := range children {
+		set := literals(child)
+		if set.isExact {
+			exact = append(exact, set.exact...)
+		} else {
+			allExact = false
+		}
+		if set.required == nil {
+			allRequired = false
+		}
+		required = append(required, set.required...)
+	}
+
+	if allExact && len(exact) <= maxLiteralSet {
+		return exactSet(dedupeLiterals(exact))
+	}
+
+	required = dedupeLiterals(required)
+	if !allRequired || len(required) > maxLiteralSet {
+		return literalSet{}
+	}
+	return literalSet{required: required}
+}
+
+// exactSet returns the set for a node matching exactly one of the strings.
+func exactSet(strs []string) literalSet {
+	return literalSet{exact: strs, isExact: true, required: usableLiterals(strs)}
+}
+
+// crossLiterals returns every string of a followed by every string of b, or false if
+// the result would be too large to be worth keeping.
+func crossLiterals(a, b []string) ([]string, bool) {
+	if len(a)*len(b) > maxLiteralSet {
+		return nil, false
+	}
+
+	joined := make([]string, 0, len(a)*len(b))
+	for _, x := range a {
+		for _, y := range b {
+			if len(x)+len(y) > maxLiteralLen {
+				return nil, false
+			}
+			joined = append(joined, x+y)
+		}
+	}
+	return dedupeLiterals(joined), true
+}
+
+// usableLiterals returns strs as a requirement, or nil if one of them is empty and so
+// the requirement says nothing.
+func usableLiterals(strs []string) []string {
+	if len(strs) == 0 || minLiteralLen(strs) == 0 {
+		return nil
+	}
+	return strs
+}
+
+// betterLiterals returns the requirement that rules out more lines: the one whose
+// shortest string is longer, then the one with fewer strings.
+func betterLiterals(a, b []string) []string {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case minLiteralLen(a) != minLiteralLen(b):
+		if minLiteralLen(a) > minLiteralLen(b) {
+			return a
+		}
+		return b
+	case len(b) < len(a):
+		return b
+	default:
+		return a
+	}
+}
+
+// minLiteralLen returns the length of the shortest string.
+func minLiteralLen(strs []string) int {
+	shortest := -1
+	for _, s := range strs {
+		if shortest == -1 || len(s) < shortest {
+			shortest = len(s)
+		}
+	}
+	return shortest
+}
+
+// dedupeLiterals removes repeated strings, keeping the first occurrence of each.
+func dedupeLiterals(strs []string) []string {
+	seen := make(map[string]bool, len(strs))
+	unique := strs[:0]
+	for _, s := range strs {
+		if !seen[s] {
+			seen[s] = true
+			unique = append(unique, s)
+		}
+	}
+	return unique
+}
+
+// literalPrefix returns the literal text every match starts with, skipping zero-width
+// assertions such as \b, or nil if there is none.
+func literalPrefix(n *Node) []byte {
+	nodes := []*Node{n}
+	if n.Kind == NodeConcat {
+		nodes = n.Children
+	}
+
+	var prefix []byte
+	for _, node := range nodes {
+		if len(prefix) == 0 && (node.Kind == NodeWordBoundary || node.Kind == NodeNotWordBoundary || node.Kind == NodeLook) {
+			continue
+		}
+		if node.Kind != NodeLiteral || node.Fold || node.Char == utf8.RuneError {
+			break
+		}
+		prefix = utf8.AppendRune(prefix, node.Char)
+	}
+	return prefix
+}
@@ -0,0 +1,161 @@
+package matcher
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLiterals(t *testing.T) {
+	tests := []struct {
+		name         string
+		pattern      string
+		wantPrefix   string
+		wantRequired []string
+	}{
+		{
+			name:         "literal",
+			pattern:      "main",
+			wantPrefix:   "main",
+			wantRequired: []string{"main"},
+		},
+		{
+			name:         "literal followed by class",
+			pattern:      "foo\\d+",
+			wantPrefix:   "foo",
+			wantRequired: []string{"foo"},
+		},
+		{
+			name:         "literal followed by anything",
+			pattern:      "ERROR:.*",
+			wantPrefix:   "ERROR:",
+			wantRequired: []string{"ERROR:"},
+		},
+		{
+			name:         "suffix",
+			pattern:      "^\\w+_test\\.go$",
+			wantPrefix:   "",
+			wantRequired: []string{"_test.go"},
+		},
+		{
+			name:         "longest literal wins",
+			pattern:      "a\\d+config\\d",
+			wantPrefix:   "a",
+			wantRequired: []string{"config"},
+		},
+		{
+			name:         "alternation of literals",
+			pattern:      "ERROR|WARN",
+			wantPrefix:   "",
+			wantRequired: []string{"ERROR", "WARN"},
+		},
+		{
+			name:         "alternation expands inside concatenation",
+			pattern:      "(ab|cd)ef",
+			wantPrefix:   "",
+			wantRequired: []string{"abef", "cdef"},
+		},
+		{
+			name:         "alternation with requirements",
+			pattern:      "\\d+px|\\d+em",
+			wantPrefix:   "",
+			wantRequired: []string{"px", "em"},
+		},
+		{
+			name:         "alternation with an unknown branch",
+			pattern:      "foo|\\d+",
+			wantPrefix:   "",
+			wantRequired: nil,
+		},
+		{
+			name:         "counted repetition",
+			pattern:      "(ab){2}",
+			wantPrefix:   "",
+			wantRequired: []string{"abab"},
+		},
+		{
+			name:         "one or more",
+			pattern:      "x(abc)+",
+			wantPrefix:   "x",
+			wantRequired: []string{"abc"},
+		},
+		{
+			name:         "optional part is not required",
+			pattern:      "(foo)?bar",
+			wantPrefix:   "",
+			wantRequired: []string{"bar"},
+		},
+		{
+			name:         "prefix after word boundary",
+			pattern:      "\\bcat\\b",
+			wantPrefix:   "cat",
+			wantRequired: []string{"cat"},
+		},
+		{
+			name:         "case-insensitive letters are unknown",
+			pattern:      "(?i)ab-12",
+			wantPrefix:   "",
+			wantRequired: []string{"-12"},
+		},
+		{
+			name:         "no literal",
+			pattern:      "\\d+",
+			wantPrefix:   "",
+			wantRequired: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re := MustCompile(tt.pattern)
+			if got := string(re.prefix); got != tt.wantPrefix {
+				t.Errorf("prefix = %q, want %q", got, tt.wantPrefix)
+			}
+
+			var got []string
+			for _, lit := range re.required {
+				got = append(got, string(lit))
+			}
+			if !reflect.DeepEqual(got, tt.wantRequired) {
+				t.Errorf("required = %q, want %q", got, tt.wantRequired)
+			}
+		})
+	}
+}
+
+func TestLiteralPrefilterKeepsMatches(t *testing.T) {
+	tests := []struct {
+		pattern string
+		line    string
+		want    []int
+	}{
+		{pattern: "foo\\d+", line: "xfoo foo12", want: []int{5, 10}},
+		{pattern: "(?<=a)b", line: "cbab", want: []int{3, 4}},
+		{pattern: "\\bcat", line: "concat cat", want: []int{7, 10}},
+		{pattern: "ERROR|WARN", line: "level=WARN", want: []int{6, 10}},
+		{pattern: "ERROR|WARN", line: "level=INFO", want: nil},
+	}
+
+	for _, tt := range tests {
+		re := MustCompile(tt.pattern)
+		if got := re.FindIndex([]byte(tt.line)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q.FindIndex(%q) = %v, want %v", tt.pattern, tt.line, got, tt.want)
+		}
+	}
+}
+
+func BenchmarkMatchRequiredLiteral(b *testing.B) {
+	re := MustCompile("ERROR: .*timeout")
+	b.SetBytes(int64(len(benchLine)))
+	for i := 0; i < b.N; i++ {
+		re.Match(benchLine)
+	}
+}
+
+func BenchmarkMatchNoLiteral(b *testing.B) {
+	re := MustCompile("ERROR: .*timeout")
+	re.required, re.prefix = nil, nil
+	b.SetBytes(int64(len(benchLine)))
+	for i := 0; i < b.N; i++ {
+		re.Match(benchLine)
+	}
+}
@@ -50,8 +50,116 @@ func TestMatch(t *testing.T) {
 			pattern: "[^abc]",
 			want:    true,
 		},
+		{
+			name:    "character range",
+			line:    "hello",
+			pattern: "^[a-z]+$",
+			want:    true,
+		},
+		{
+			name:    "character range excludes outside",
+			line:    "Hello",
+			pattern: "^[a-z]+$",
+			want:    false,
+		},
+		{
+			name:    "multiple ranges",
+			line:    "C0FFEE",
+			pattern: "^[0-9A-F]+$",
+			want:    true,
+		},
+		{
+			name:    "negated range",
+			line:    "abc",
+			pattern: "[^a-z]",
+			want:    false,
+		},
+		{
+			name:    "leading bracket is literal",
+			line:    "a]b",
+			pattern: "[]]",
+			want:    true,
+		},
+		{
+			name:    "escaped bracket and dash",
+			line:    "x-y",
+			pattern: "^x[\\]\\-]y$",
+			want:    true,
+		},
+		{
+			name:    "trailing dash is literal",
+			line:    "-",
+			pattern: "^[a-]$",
+			want:    true,
+		},
+		{
+			name:    "escape class inside brackets",
+			line:    "v1_2",
+			pattern: "^[\\d_v]+$",
+			want:    true,
+		},
+		{
+			name:    "POSIX alpha class",
+			line:    "abcXYZ",
+			pattern: "^[[:alpha:]]+$",
+			want:    true,
+		},
+		{
+			name:    "POSIX space class",
+			line:    "a\tb",
+			pattern: "a[[:space:]]b",
+			want:    true,
+		},
+		{
+			name:    "negated POSIX class",
+			line:    "123",
+			pattern: "[^[:digit:]]",
+			want:    false,
+		},
+		{
+			name:    "negated POSIX class name",
+			line:    "123",
+			pattern: "[[:^digit:]]",
+			want:    false,
+		},
+		{
+			name:    "negated POSIX class name matches others",
+			line:    "12a",
+			pattern: "^\\d+[[:^digit:]]$",
+			want:    true,
+		},
+		{
+			name:    "negated POSIX class name mixed with members",
+			line:    "ab7",
+			pattern: "^[[:^alpha:]ab]+$",
+			want:    true,
+		},
+		{
+			name:    "negated bracket of negated POSIX class name",
+			line:    "x",
+			pattern: "[^[:^alpha:]]",
+			want:    true,
+		},
+		{
+			name:    "POSIX class mixed with members",
+			line:    "file-name.go",
+			pattern: "^[[:alnum:].-]+$",
+			want:    true,
+		},
 
 		// Escape sequences
+		{
+			name:    "text anchors",
+			line:    "foo",
+			pattern: "\\Afoo\\z",
+			want:    true,
+		},
+		{
+			name:    "text anchors are not letters",
+			line:    "Afooz",
+			pattern: "\\Afoo\\z",
+			want:    false,
+		},
 		{
 			name:    "digit match",
 			line:    "123",
@@ -84,6 +192,204 @@ func TestMatch(t *testing.T) {
 			pattern: "a?b",
 			want:    true,
 		},
+		{
+			name:    "whitespace escape",
+			line:    "two words",
+			pattern: "two\\sw",
+			want:    true,
+		},
+		{
+			name:    "non-whitespace escape",
+			line:    "   ",
+			pattern: "\\S",
+			want:    false,
+		},
+		{
+			name:    "non-digit escape",
+			line:    "123",
+			pattern: "\\D",
+			want:    false,
+		},
+		{
+			name:    "non-word escape",
+			line:    "a-b",
+			pattern: "^\\w\\W\\w$",
+			want:    true,
+		},
+		{
+			name:    "negated escapes inside brackets",
+			line:    "x",
+			pattern: "^[\\D\\d]$",
+			want:    true,
+		},
+		{
+			name:    "tab escape",
+			line:    "a\tb",
+			pattern: "a\\tb",
+			want:    true,
+		},
+		{
+			name:    "newline escape",
+			line:    "a\nb",
+			pattern: "a\\nb",
+			want:    true,
+		},
+		{
+			name:    "two digit hex escape",
+			line:    "A",
+			pattern: "^\\x41$",
+			want:    true,
+		},
+		{
+			name:    "braced unicode escape",
+			line:    "smile 😀",
+			pattern: "\\u{1F600}",
+			want:    true,
+		},
+		{
+			name:    "four digit unicode escape",
+			line:    "é",
+			pattern: "^\\u00e9$",
+			want:    true,
+		},
+
+		// Word boundaries
+		{
+			name:    "word boundary around identifier",
+			line:    "call id(x)",
+			pattern: "\\bid\\b",
+			want:    true,
+		},
+		{
+			name:    "word boundary rejects partial identifier",
+			line:    "width idx",
+			pattern: "\\bid\\b",
+			want:    false,
+		},
+		{
+			name:    "not word boundary",
+			line:    "width",
+			pattern: "\\Bid",
+			want:    true,
+		},
+		{
+			name:    "not word boundary at word start",
+			line:    "id",
+			pattern: "\\Bid",
+			want:    false,
+		},
+		{
+			name:    "word boundary with unicode letters",
+			line:    "éid",
+			pattern: "\\bid",
+			want:    false,
+		},
+
+		// Quantifiers on classes, escapes, dot and groups
+		{
+			name:    "plus on digit escape",
+			line:    "file123.txt",
+			pattern: "^file\\d+\\.txt$",
+			want:    true,
+		},
+		{
+			name:    "star on word escape",
+			line:    "my_file.go",
+			pattern: "^\\w*\\.go$",
+			want:    true,
+		},
+		{
+			name:    "star on character class",
+			line:    "cabbage",
+			pattern: "^[abc]*ge$",
+			want:    true,
+		},
+		{
+			name:    "plus on negated character class",
+			line:    "src/main.go",
+			pattern: "^[^/]+/[^/]+$",
+			want:    true,
+		},
+		{
+			name:    "plus on negated character class rejects separator",
+			line:    "src/cmd/main.go",
+			pattern: "^[^/]+/[^/]+$",
+			want:    false,
+		},
+		{
+			name:    "plus on dot",
+			line:    "abc",
+			pattern: "^a.+c$",
+			want:    true,
+		},
+		{
+			name:    "plus on dot needs a character",
+			line:    "ac",
+			pattern: "^a.+c$",
+			want:    false,
+		},
+		{
+			name:    "optional group",
+			line:    "color",
+			pattern: "^colo(u)?r$",
+			want:    true,
+		},
+		{
+			name:    "plus on backreference",
+			line:    "abbb",
+			pattern: "^a(b)\\1+$",
+			want:    true,
+		},
+
+		// Counted repetition
+		{
+			name:    "exact count on escape",
+			line:    "app-2024-01-15.log",
+			pattern: "\\d{4}-\\d{2}-\\d{2}",
+			want:    true,
+		},
+		{
+			name:    "exact count too few",
+			line:    "app-24-01-15.log",
+			pattern: "\\d{4}-\\d{2}-\\d{2}",
+			want:    false,
+		},
+		{
+			name:    "range upper bound",
+			line:    "aaaa",
+			pattern: "^a{2,3}$",
+			want:    false,
+		},
+		{
+			name:    "range within bounds",
+			line:    "aaa",
+			pattern: "^a{2,3}$",
+			want:    true,
+		},
+		{
+			name:    "at least",
+			line:    "aaaaa",
+			pattern: "^a{2,}$",
+			want:    true,
+		},
+		{
+			name:    "count on group",
+			line:    "ababx",
+			pattern: "^(ab){2}x",
+			want:    true,
+		},
+		{
+			name:    "count on class",
+			line:    "cab",
+			pattern: "^[abc]{3}$",
+			want:    true,
+		},
+		{
+			name:    "brace without count is literal",
+			line:    "a{x}",
+			pattern: "a{x}",
+			want:    true,
+		},
 
 		// Wildcards
 		{
@@ -93,6 +399,62 @@ func TestMatch(t *testing.T) {
 			want:    true,
 		},
 
+		// UTF-8
+		{
+			name:    "dot matches a whole multibyte character",
+			line:    "é",
+			pattern: "^.$",
+			want:    true,
+		},
+		{
+			name:    "word escape matches accented letters",
+			line:    "café.txt",
+			pattern: "^\\w+\\.txt$",
+			want:    true,
+		},
+		{
+			name:    "multibyte literal",
+			line:    "naïve",
+			pattern: "ï",
+			want:    true,
+		},
+		{
+			name:    "multibyte class member",
+			line:    "ü",
+			pattern: "^[äöü]$",
+			want:    true,
+		},
+		{
+			name:    "multibyte range",
+			line:    "λόγος",
+			pattern: "^[α-ωά-ώ]+$",
+			want:    true,
+		},
+		{
+			name:    "unicode script class",
+			line:    "file-αβγ.txt",
+			pattern: "\\p{Greek}+",
+			want:    true,
+		},
+		{
+			name:    "unicode category shorthand",
+			line:    "Ж",
+			pattern: "^\\pL$",
+			want:    true,
+		},
+		{
+			name:    "negated unicode class",
+			line:    "abc",
+			pattern: "\\P{L}",
+			want:    false,
+		},
+		{
+			name:    "unicode class inside brackets",
+			line:    "π1",
+			pattern: "^[\\p{Greek}\\d]+$",
+			want:    true,
+		},
+
 		// Alternation
 		{
 			name:    "alternation",
@@ -100,6 +462,334 @@ func TestMatch(t *testing.T) {
 			pattern: "(cat|dog)",
 			want:    true,
 		},
+		{
+			name:    "alternation checks the rest of the pattern",
+			line:    "cat",
+			pattern: "(cat|dog)s",
+			want:    false,
+		},
+		{
+			name:    "alternation falls through to later branch",
+			line:    "doghouse",
+			pattern: "(do|dog)house",
+			want:    true,
+		},
+
+		// Groups
+		{
+			name:    "quantified group",
+			line:    "abab!",
+			pattern: "^(ab)+!",
+			want:    true,
+		},
+		{
+			name:    "nested alternation in group",
+			line:    "a dog and a cat",
+			pattern: "a (dog|(c|r)at) and a (dog|(c|r)at)",
+			want:    true,
+		},
+
+		// Backreferences
+		{
+			name:    "single backreference",
+			line:    "cat and cat",
+			pattern: "(cat) and \\1",
+			want:    true,
+		},
+		{
+			name:    "single backreference mismatch",
+			line:    "cat and dog",
+			pattern: "(cat) and \\1",
+			want:    false,
+		},
+		{
+			name:    "backreference to quantified class",
+			line:    "this starts and ends with this",
+			pattern: "^(\\w+) starts and ends with \\1$",
+			want:    true,
+		},
+		{
+			name:    "backreference needs backtracking",
+			line:    "abcabc",
+			pattern: "^(\\w+)\\1$",
+			want:    true,
+		},
+		{
+			name:    "nested backreferences",
+			line:    "'cat and cat' is the same as 'cat and cat'",
+			pattern: "('(cat) and \\2') is the same as \\1",
+			want:    true,
+		},
+		{
+			name:    "backreference to alternation",
+			line:    "ab",
+			pattern: "(a|b)\\1",
+			want:    false,
+		},
+		{
+			name:    "backreference inside alternation",
+			line:    "dog-dog",
+			pattern: "(cat|dog)-(\\1|fish)",
+			want:    true,
+		},
+		{
+			name:    "backreference after empty loop",
+			line:    "aa",
+			pattern: "^(a*)*\\1$",
+			want:    true,
+		},
+
+		// Lookarounds
+		{
+			name:    "negative lookahead excludes test files",
+			line:    "main_test.go",
+			pattern: "^(?!.*_test\\.go$).*\\.go$",
+			want:    false,
+		},
+		{
+			name:    "negative lookahead keeps other go files",
+			line:    "main.go",
+			pattern: "^(?!.*_test\\.go$).*\\.go$",
+			want:    true,
+		},
+		{
+			name:    "positive lookahead",
+			line:    "config.yaml",
+			pattern: "^\\w+(?=\\.ya?ml$)",
+			want:    true,
+		},
+		{
+			name:    "negative lookbehind",
+			line:    "#foo",
+			pattern: "(?<!#)foo",
+			want:    false,
+		},
+		{
+			name:    "negative lookbehind without prefix",
+			line:    "x foo",
+			pattern: "(?<!#)foo",
+			want:    true,
+		},
+		{
+			name:    "positive lookbehind with anchor",
+			line:    "ab",
+			pattern: "(?<=^a)b",
+			want:    true,
+		},
+		{
+			name:    "lookbehind over multibyte character",
+			line:    "éx",
+			pattern: "(?<=é)x",
+			want:    true,
+		},
+		{
+			name:    "lookbehind of alternatives with different lengths",
+			line:    "xabcd",
+			pattern: "(?<=ab|abc)d",
+			want:    true,
+		},
+		{
+			name:    "lookbehind with bounded repetition",
+			line:    "xaaab",
+			pattern: "(?<=xa{2,3})b",
+			want:    true,
+		},
+		{
+			name:    "lookbehind with bounded repetition too short",
+			line:    "xab",
+			pattern: "(?<=xa{2,3})b",
+			want:    false,
+		},
+		{
+			name:    "case-insensitive lookbehind over wider case variant",
+			line:    "\u212Ab",
+			pattern: "(?i)(?<=k)b",
+			want:    true,
+		},
+		{
+			name:    "unbounded lookbehind",
+			line:    "a----b",
+			pattern: "(?<=a-*)b",
+			want:    true,
+		},
+		{
+			name:    "backreference inside lookbehind",
+			line:    "aa-aa",
+			pattern: "^(a+)-.*(?<=-\\1)$",
+			want:    true,
+		},
+		{
+			name:    "backreference to group inside lookahead",
+			line:    "aaxy",
+			pattern: "(?=(a))\\1",
+			want:    true,
+		},
+		{
+			name:    "backreference to group inside lookbehind",
+			line:    "ab-b",
+			pattern: "(?<=a(b))-\\1",
+			want:    true,
+		},
+		{
+			name:    "group inside lookahead is undone on backtracking",
+			line:    "aa",
+			pattern: "^(?:(?=(a))ax|a)\\1",
+			want:    false,
+		},
+		{
+			name:    "group inside lookahead captures a whole word",
+			line:    "abc:",
+			pattern: "(?=(\\w+))\\1:",
+			want:    true,
+		},
+		{
+			name:    "backreference inside lookahead",
+			line:    "ab-ab",
+			pattern: "^(\\w+)(?=-\\1$)",
+			want:    true,
+		},
+
+		// Backtracking into quantifiers
+		{
+			name:    "star gives characters back",
+			line:    "aaab",
+			pattern: "a*ab",
+			want:    true,
+		},
+		{
+			name:    "plus gives characters back",
+			line:    "aaa",
+			pattern: "^a+a$",
+			want:    true,
+		},
+		{
+			name:    "optional gives character back",
+			line:    "a",
+			pattern: "^a?a$",
+			want:    true,
+		},
+		{
+			name:    "dot star followed by literal",
+			line:    "main_test.go",
+			pattern: "^.*_test\\.go$",
+			want:    true,
+		},
+
+		// Inline flags
+		{
+			name:    "case-insensitive literal",
+			line:    "README.md",
+			pattern: "(?i)readme",
+			want:    true,
+		},
+		{
+			name:    "case-insensitive class",
+			line:    "MAIN.GO",
+			pattern: "(?i)^[a-z]+\\.go$",
+			want:    true,
+		},
+		{
+			name:    "case-insensitive negated class",
+			line:    "A",
+			pattern: "(?i)[^a]",
+			want:    false,
+		},
+		{
+			name:    "case-insensitive non-ASCII",
+			line:    "ÉTÉ",
+			pattern: "(?i)été",
+			want:    true,
+		},
+		{
+			name:    "case-insensitive Kelvin sign",
+			line:    "\u212A",
+			pattern: "(?i)k",
+			want:    true,
+		},
+		{
+			name:    "case-insensitive keeps \\W",
+			line:    "A",
+			pattern: "(?i)\\W",
+			want:    false,
+		},
+		{
+			name:    "case-insensitive keeps \\D",
+			line:    "5",
+			pattern: "(?i)\\D",
+			want:    false,
+		},
+		{
+			name:    "case-insensitive backreference",
+			line:    "abc-ABC",
+			pattern: "(?i)^(\\w+)-\\1$",
+			want:    true,
+		},
+		{
+			name:    "backreference is case-sensitive by default",
+			line:    "abc-ABC",
+			pattern: "^(\\w+)-\\1$",
+			want:    false,
+		},
+		{
+			name:    "scoped flag group",
+			line:    "aB",
+			pattern: "^a(?i:b)$",
+			want:    true,
+		},
+		{
+			name:    "scoped flag group ends with group",
+			line:    "AB",
+			pattern: "^a(?i:b)$",
+			want:    false,
+		},
+		{
+			name:    "flag ends with enclosing group",
+			line:    "aBC",
+			pattern: "^a((?i)b)c$",
+			want:    false,
+		},
+		{
+			name:    "flag cleared",
+			line:    "Ab",
+			pattern: "(?i)a(?-i)b",
+			want:    true,
+		},
+		{
+			name:    "flag cleared no match",
+			line:    "AB",
+			pattern: "(?i)a(?-i)b",
+			want:    false,
+		},
+		{
+			name:    "dot does not match newline",
+			line:    "a\nb",
+			pattern: "a.b",
+			want:    false,
+		},
+		{
+			name:    "dot-all dot matches newline",
+			line:    "a\nb",
+			pattern: "(?s)a.b",
+			want:    true,
+		},
+		{
+			name:    "caret matches only at start",
+			line:    "a\nb",
+			pattern: "^b",
+			want:    false,
+		},
+		{
+			name:    "multiline caret",
+			line:    "a\nb",
+			pattern: "(?m)^b",
+			want:    true,
+		},
+		{
+			name:    "multiline dollar",
+			line:    "a\nb",
+			pattern: "(?m)a$",
+			want:    true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -0,0 +1,138 @@
+package matcher
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// MaxWindowSize is the most text ScanReaderMultiline holds and matches at once.
+const MaxWindowSize = 16 << 20
+
+// SpanMatch is a match found by ScanBuffer or ScanReaderMultiline, which may cross line
+// boundaries. Text points into the scanned buffer; for ScanReaderMultiline it is only
+// valid until the callback returns.
+type SpanMatch struct {
+	Line      int    // 1-based number of the line the match starts on
+	Column    int    // 1-based byte offset of the match in its first line
+	EndLine   int    // 1-based number of the line holding the byte just after the match
+	EndColumn int    // 1-based byte offset in EndLine of the byte just after the match
+	Offset    int64  // byte offset of the match from the start of the text
+	Text      []byte // the matched text, including any line terminators inside it
+}
+
+// ScanBuffer matches the pattern against a whole text at once rather than line by line,
+// and calls fn with every match, in order, until fn returns false. Matches may span
+// lines: the pattern sees every '\n', which it can match explicitly, and if compiled
+// with the Multiline option its '^' and '$' match at the start and end of every line.
+// Line terminators are not translated, so "\r\n" has to be matched as such.
+//
+// Parameters:
+// - buf: The text to scan.
+// - fn: The function called for each match; returning false stops the scan.
+func (re *Regexp) ScanBuffer(buf []byte, fn func(SpanMatch) bool) {
+	re.scanWindow(buf, &cursor{line: 1}, fn)
+}
+
+// ScanReaderMultiline is like ScanBuffer but reads the text from r. The text is matched
+// in windows of whole lines of up to MaxWindowSize bytes, so memory use stays bounded
+// but a match straddling two windows is not found, and '^' and '$' compiled without the
+// Multiline option match at every window boundary. A single line longer than a window
+// is split across windows.
+//
+// Parameters:
+// - r: The reader to scan.
+// - fn: The function called for each match; returning false stops the scan.
+//
+// Returns:
+// - error: The first error returned by the reader other than io.EOF, or nil.
+func (re *Regexp) ScanReaderMultiline(r io.Reader, fn func(SpanMatch) bool) error {
+	return re.scanReaderMultiline(r, fn, MaxWindowSize)
+}
+
+// scanReaderMultiline is ScanReaderMultiline with the window size as a parameter. The
+// buffer starts small and doubles while the reader fills it, up to the window size, so
+// short texts do not pay for a whole window.
+func (re *Regexp) scanReaderMultiline(r io.Reader, fn func(SpanMatch) bool, windowSize int) error {
+	buf := make([]byte, min(scanBufferSize, windowSize))
+	filled := 0
+	c := &cursor{line: 1}
+
+	for {
+		n, err := io.ReadFull(r, buf[filled:])
+		filled += n
+		eof := errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+		if err != nil && !eof {
+			return err
+		}
+
+		if !eof && len(buf) < windowSize {
+			grown := make([]byte, min(2*len(buf), windowSize))
+			copy(grown, buf[:filled])
+			buf = grown
+			continue
+		}
+
+		window := buf[:filled]
+		if !eof {
+			if i := bytes.LastIndexByte(window, '\n'); i >= 0 {
+				window = window[:i+1]
+			}
+		}
+
+		if len(window) > 0 && !re.scanWindow(window, c, fn) {
+			return nil
+		}
+		if eof {
+			return nil
+		}
+
+		filled = copy(buf, buf[len(window):filled])
+	}
+}
+
+// cursor tracks the line and column of an offset as a scan moves forward through a text.
+type cursor struct {
+	offset int64 // offset of the position from the start of the text
+	line   int
+	column int // 0-based
+}
+
+// advance moves the cursor forward over text, which starts at its position.
+func (c *cursor) advance(text []byte) {
+	c.offset += int64(len(text))
+	if lines := bytes.Count(text, []byte{'\n'}); lines > 0 {
+		c.line += lines
+		c.column = len(text) - bytes.LastIndexByte(text, '\n') - 1
+		return
+	}
+	c.column += len(text)
+}
+
+// scanWindow calls fn with every match in a window that starts at the cursor's
+// position, and leaves the cursor at the window's end. It reports whether fn asked
+// for more matches.
+func (re *Regexp) scanWindow(window []byte, c *cursor, fn func(SpanMatch) bool) bool {
+	pos := 0
+	for _, loc := range re.FindAllIndex(window, -1) {
+		c.advance(window[pos:loc[0]])
+		start := *c
+		c.advance(window[loc[0]:loc[1]])
+		pos = loc[1]
+
+		m := SpanMatch{
+			Line:      start.line,
+			Column:    start.column + 1,
+			EndLine:   c.line,
+			EndColumn: c.column + 1,
+			Offset:    start.offset,
+			Text:      window[loc[0]:loc[1]],
+		}
+		if !fn(m) {
+			return false
+		}
+	}
+
+	c.advance(window[pos:])
+	return true
+}
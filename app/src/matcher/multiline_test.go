@@ -0,0 +1,133 @@
+package matcher
+
+import (
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// spanned is a SpanMatch with its text copied out of the scanner's buffer.
+type spanned struct {
+	Line, Column, EndLine, EndColumn int
+	Offset                           int64
+	Text                             string
+}
+
+func TestRegexpScanBuffer(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		opts    CompileOptions
+		input   string
+		want    []spanned
+	}{
+		{
+			name:    "signature followed by first statement",
+			pattern: "func (\\w+)\\(\\) \\{\\n\\s*defer",
+			input:   "package x\n\nfunc a() {\n\treturn\n}\n\nfunc b() {\n\tdefer f()\n}\n",
+			want:    []spanned{{7, 1, 8, 7, 33, "func b() {\n\tdefer"}},
+		},
+		{
+			name:    "yaml key followed by value",
+			pattern: "^image:\\n\\s+tag: (\\S+)$",
+			opts:    CompileOptions{Multiline: true},
+			input:   "name: web\nimage:\n  tag: v1.2\nports: []\n",
+			want:    []spanned{{2, 1, 3, 12, 10, "image:\n  tag: v1.2"}},
+		},
+		{
+			name:    "anchors are line-aware in multiline mode",
+			pattern: "^\\w+$",
+			opts:    CompileOptions{Multiline: true},
+			input:   "one\ntwo three\nfour",
+			want: []spanned{
+				{1, 1, 1, 4, 0, "one"},
+				{3, 1, 3, 5, 14, "four"},
+			},
+		},
+		{
+			name:    "match ending with a newline",
+			pattern: "b\\n",
+			input:   "ab\nc",
+			want:    []spanned{{1, 2, 2, 1, 1, "b\n"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := CompileWithOptions(tt.pattern, tt.opts)
+			if err != nil {
+				t.Fatalf("CompileWithOptions() error = %v", err)
+			}
+
+			var got []spanned
+			re.ScanBuffer([]byte(tt.input), func(m SpanMatch) bool {
+				got = append(got, spanned{m.Line, m.Column, m.EndLine, m.EndColumn, m.Offset, string(m.Text)})
+				return true
+			})
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ScanBuffer() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegexpScanReaderMultilineWindows(t *testing.T) {
+	input := "a1\nb\na2\nb\n" + strings.Repeat("x", 17) + "a3\nb\n"
+	re := MustCompile("a\\d\\nb")
+
+	var got []spanned
+	err := re.scanReaderMultiline(strings.NewReader(input), func(m SpanMatch) bool {
+		got = append(got, spanned{m.Line, m.Column, m.EndLine, m.EndColumn, m.Offset, string(m.Text)})
+		return true
+	}, 10)
+	if err != nil {
+		t.Fatalf("ScanReaderMultiline() error = %v", err)
+	}
+
+	// The windows of 10 bytes hold "a1\nb\na2\nb\n", then the long line split after
+	// ten x's, then its rest up to "a3\n", so the last match straddles two windows and
+	// is not found.
+	want := []spanned{
+		{1, 1, 2, 2, 0, "a1\nb"},
+		{3, 1, 4, 2, 5, "a2\nb"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ScanReaderMultiline() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRegexpScanReaderMultilineGrowsBuffer(t *testing.T) {
+	// The match straddles the end of the initial buffer, which grows to take it in.
+	input := strings.Repeat("x\n", scanBufferSize/2-1) + "a\nb\n"
+	re := MustCompile("a\\nb")
+
+	var got []int64
+	err := re.scanReaderMultiline(iotest.HalfReader(strings.NewReader(input)), func(m SpanMatch) bool {
+		got = append(got, m.Offset)
+		return true
+	}, 4*scanBufferSize)
+	if err != nil {
+		t.Fatalf("ScanReaderMultiline() error = %v", err)
+	}
+
+	if want := []int64{scanBufferSize - 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ScanReaderMultiline() offsets = %v, want %v", got, want)
+	}
+}
+
+func TestRegexpScanReaderMultilineSmallInput(t *testing.T) {
+	re := MustCompile("b")
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	if err := re.ScanReaderMultiline(strings.NewReader("abc\n"), func(SpanMatch) bool { return true }); err != nil {
+		t.Fatalf("ScanReaderMultiline() error = %v", err)
+	}
+	runtime.ReadMemStats(&after)
+
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > MaxWindowSize/16 {
+		t.Errorf("ScanReaderMultiline() allocated %d bytes for a 4 byte input", allocated)
+	}
+}
@@ -0,0 +1,710 @@
+package matcher
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// parser turns a pattern string into a tree of Nodes.
+type parser struct {
+	pattern  string
+	pos      int
+	ncap     int            // number of capture groups seen so far
+	names    []string       // capture group names indexed by group number
+	backrefs []backref      // backreferences, checked against ncap once parsing is done
+	flags    CompileOptions // flags in effect at the current position
+	glob     bool           // parsing a glob, where bracket expressions may also be negated with '!'
+}
+
+// backref records where a backreference appeared so it can be reported if its group
+// does not exist.
+type backref struct {
+	node   *Node
+	offset int
+}
+
+// parse parses a pattern into its abstract syntax tree.
+//
+// Parameters:
+// - pattern: The pattern string to be parsed.
+// - opts: The flags in effect at the start of the pattern.
+//
+// Returns:
+// - *parser: The parser state, holding the capture group count and names.
+// - *Node: The root node of the parsed pattern.
+// - error: A *SyntaxError if the pattern is malformed.
+func parse(pattern string, opts CompileOptions) (*parser, *Node, error) {
+	p := &parser{pattern: pattern, names: []string{""}, flags: opts}
+
+	node, err := p.parseAlternation()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !p.done() {
+		return nil, nil, p.errorf(p.pos, "unmatched )")
+	}
+
+	for _, ref := range p.backrefs {
+		if ref.node.Index > p.ncap {
+			return nil, nil, p.errorf(ref.offset, "backreference \\%d refers to a missing group", ref.node.Index)
+		}
+	}
+	return p, node, nil
+}
+
+// parseAlternation parses one or more concatenations separated by '|'.
+func (p *parser) parseAlternation() (*Node, error) {
+	first, err := p.parseConcat()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.done() || p.peek() != OrCharacter {
+		return first, nil
+	}
+
+	alt := &Node{Kind: NodeAlternate, Children: []*Node{first}}
+	for !p.done() && p.peek() == OrCharacter {
+		p.pos++
+		next, err := p.parseConcat()
+		if err != nil {
+			return nil, err
+		}
+		alt.Children = append(alt.Children, next)
+	}
+	return alt, nil
+}
+
+// parseConcat parses a sequence of quantified atoms up to a '|', a ')' or the end of the pattern.
+// A quantifier applies to whatever atom precedes it, be it a literal, '.', an escape, a class,
+// a group or a backreference. Stacking quantifiers, as in a**, is an error.
+func (p *parser) parseConcat() (*Node, error) {
+	concat := &Node{Kind: NodeConcat}
+
+	for !p.done() && p.peek() != OrCharacter && p.peek() != RightParen {
+		atom, err := p.parseAtom()
+		if err != nil {
+			return nil, err
+		}
+
+		if !p.done() {
+			atom, err = p.parseQuantifier(atom)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if !p.done() && atom.Kind == NodeRepeat {
+			start := p.pos
+			nested, err := p.parseQuantifier(atom)
+			if err != nil {
+				return nil, err
+			}
+			if nested != atom {
+				return nil, p.errorf(start, "invalid nested repetition operator")
+			}
+		}
+		concat.Children = append(concat.Children, atom)
+	}
+
+	switch len(concat.Children) {
+	case 0:
+		return &Node{Kind: NodeEmpty}, nil
+	case 1:
+		return concat.Children[0], nil
+	default:
+		return concat, nil
+	}
+}
+
+// parseQuantifier wraps atom in a repeat node if a quantifier follows at the current
+// position, and returns atom unchanged otherwise. A trailing '?' makes the quantifier
+// lazy and a trailing '+' makes it possessive.
+func (p *parser) parseQuantifier(atom *Node) (*Node, error) {
+	if !isQuantifier(p.peek()) {
+		return atom, nil
+	}
+	repeat := &Node{Kind: NodeRepeat, Children: []*Node{atom}}
+
+	switch p.peek() {
+	case ZeroOrMore:
+		repeat.Min, repeat.Max = 0, -1
+		p.pos++
+	case OneOrMore:
+		repeat.Min, repeat.Max = 1, -1
+		p.pos++
+	case ZeroOrOne:
+		repeat.Min, repeat.Max = 0, 1
+		p.pos++
+	case LeftBrace:
+		min, max, ok, err := p.parseRepeatBounds()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return atom, nil
+		}
+		repeat.Min, repeat.Max = min, max
+	}
+
+	if !p.done() {
+		switch p.peek() {
+		case ZeroOrOne:
+			repeat.Mode = RepeatLazy
+			p.pos++
+		case OneOrMore:
+			repeat.Mode = RepeatPossessive
+			p.pos++
+		}
+	}
+
+	return repeat, nil
+}
+
+// parseRepeatBounds parses a counted repetition {n}, {n,} or {n,m} at the current position.
+// When the brace does not start a counted repetition, ok is false, the position is left
+// unchanged and the brace is matched literally.
+//
+// Returns:
+// - int: The minimum number of repetitions.
+// - int: The maximum number of repetitions, or -1 if unbounded.
+// - bool: True if a counted repetition was parsed.
+// - error: An error if the bounds are out of order or too large.
+func (p *parser) parseRepeatBounds() (int, int, bool, error) {
+	start := p.pos
+	end := strings.IndexByte(p.pattern[start:], RightBrace)
+	if end == -1 {
+		return 0, 0, false, nil
+	}
+
+	body := p.pattern[start+1 : start+end]
+	minStr, maxStr, hasComma := strings.Cut(body, ",")
+
+	min, ok := parseRepeatCount(minStr)
+	if !ok {
+		return 0, 0, false, nil
+	}
+
+	max := min
+	if hasComma {
+		max = -1
+		if maxStr != "" {
+			if max, ok = parseRepeatCount(maxStr); !ok {
+				return 0, 0, false, nil
+			}
+		}
+	}
+
+	if min > maxRepeat || max > maxRepeat {
+		return 0, 0, false, p.errorf(start, "repetition count in {%s} exceeds %d", body, maxRepeat)
+	}
+	if max != -1 && max < min {
+		return 0, 0, false, p.errorf(start, "invalid repetition range {%s}", body)
+	}
+
+	p.pos = start + end + 1
+	return min, max, true, nil
+}
+
+// parseRepeatCount parses the decimal count of a counted repetition.
+func parseRepeatCount(s string) (int, bool) {
+	if s == "" || len(s) > 9 {
+		return 0, false
+	}
+
+	n := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return 0, false
+		}
+		n = n*10 + int(s[i]-'0')
+	}
+	return n, true
+}
+
+// parseAtom parses a single atom: a literal, an escape, a class, an anchor or a group.
+// A quantifier where an atom should be has nothing to repeat and is an error, as is an
+// escaped letter or digit with no meaning, such as \e.
+func (p *parser) parseAtom() (*Node, error) {
+	c := p.peek()
+
+	switch c {
+	case StartsWith:
+		p.pos++
+		if p.flags.Multiline {
+			return &Node{Kind: NodeBeginLine}, nil
+		}
+		return &Node{Kind: NodeBeginText}, nil
+
+	case EndsWith:
+		p.pos++
+		if p.flags.Multiline {
+			return &Node{Kind: NodeEndLine}, nil
+		}
+		return &Node{Kind: NodeEndText}, nil
+
+	case AnyCharacter:
+		p.pos++
+		if p.flags.DotAll {
+			return &Node{Kind: NodeAnyChar}, nil
+		}
+		return &Node{Kind: NodeAnyCharNotNL}, nil
+
+	case Backslash:
+		start := p.pos
+		if p.pos+1 < len(p.pattern) && p.pattern[p.pos+1] == WordBoundary {
+			p.pos += 2
+			return &Node{Kind: NodeWordBoundary}, nil
+		}
+
+		if p.pos+1 < len(p.pattern) && p.pattern[p.pos+1] == NotWordBoundary {
+			p.pos += 2
+			return &Node{Kind: NodeNotWordBoundary}, nil
+		}
+
+		if p.pos+1 < len(p.pattern) && p.pattern[p.pos+1] == BeginText {
+			p.pos += 2
+			return &Node{Kind: NodeBeginText}, nil
+		}
+
+		if p.pos+1 < len(p.pattern) && p.pattern[p.pos+1] == EndText {
+			p.pos += 2
+			return &Node{Kind: NodeEndText}, nil
+		}
+
+		if p.pos+1 < len(p.pattern) && p.pattern[p.pos+1] >= '1' && p.pattern[p.pos+1] <= '9' {
+			ref := &Node{Kind: NodeBackref, Index: int(p.pattern[p.pos+1] - '0'), Fold: p.flags.CaseInsensitive}
+			p.backrefs = append(p.backrefs, backref{node: ref, offset: start})
+			p.pos += 2
+			return ref, nil
+		}
+
+		char, class, err := p.parseEscape()
+		if err != nil {
+			return nil, err
+		}
+
+		if class != nil {
+			if p.flags.CaseInsensitive {
+				class = class.folded()
+			}
+			return &Node{Kind: NodeEscape, Char: rune(p.pattern[start+1]), Class: class, Text: p.pattern[start:p.pos]}, nil
+		}
+		return p.literal(char), nil
+
+	case LeftBracket:
+		return p.parseCharClass()
+
+	case LeftParen:
+		return p.parseGroup()
+
+	case ZeroOrMore, OneOrMore, ZeroOrOne:
+		return nil, p.errorf(p.pos, "missing argument to repetition operator")
+
+	case LeftBrace:
+		start := p.pos
+		if _, _, ok, err := p.parseRepeatBounds(); ok || err != nil {
+			return nil, p.errorf(start, "missing argument to repetition operator")
+		}
+		return p.literal(p.nextRune()), nil
+
+	default:
+		return p.literal(p.nextRune()), nil
+	}
+}
+
+// literal returns the node for a literal character, which matches its case variants
+// too when the case-insensitive flag is set.
+func (p *parser) literal(char rune) *Node {
+	return &Node{Kind: NodeLiteral, Char: char, Fold: p.flags.CaseInsensitive && canFold(char)}
+}
+
+// parseEscape parses the escape sequence starting at the current backslash. It returns
+// either the escaped character, or the class for an escape class such as \d or \p{Greek}.
+func (p *parser) parseEscape() (rune, *CharClass, error) {
+	start := p.pos
+	if p.pos+1 >= len(p.pattern) {
+		return 0, nil, p.errorf(start, "trailing backslash")
+	}
+	p.pos++
+
+	escaped := p.peek()
+	if escaped == UnicodeClass || escaped == NotUnicodeClass {
+		p.pos++
+		class, err := p.parseUnicodeClass(escaped == NotUnicodeClass, start)
+		return 0, class, err
+	}
+
+	if class := escapeClass(escaped); class != nil {
+		p.pos++
+		return 0, class, nil
+	}
+
+	if escaped == HexEscape || escaped == UnicodeEscape {
+		p.pos++
+		char, err := p.parseHexEscape(escaped, start)
+		return char, nil, err
+	}
+
+	if char, ok := escapeLiterals[escaped]; ok {
+		p.pos++
+		return char, nil, nil
+	}
+
+	if escaped < utf8.RuneSelf && (unicode.IsLetter(rune(escaped)) || unicode.IsDigit(rune(escaped))) {
+		return 0, nil, p.errorf(start, "invalid escape sequence \\%c", escaped)
+	}
+	return p.nextRune(), nil, nil
+}
+
+// parseHexEscape parses the code point following \x or \u. \x takes exactly two hex
+// digits, \u exactly four, and both accept any number of digits in braces, as in \u{1F600}.
+func (p *parser) parseHexEscape(escaped byte, start int) (rune, error) {
+	var digits string
+	if !p.done() && p.peek() == LeftBrace {
+		end := strings.IndexByte(p.pattern[p.pos:], RightBrace)
+		if end == -1 {
+			return 0, p.errorf(start, "missing closing } for hex escape")
+		}
+		digits = p.pattern[p.pos+1 : p.pos+end]
+		p.pos += end + 1
+	} else {
+		n := 2
+		if escaped == UnicodeEscape {
+			n = 4
+		}
+		if p.pos+n > len(p.pattern) {
+			return 0, p.errorf(start, "incomplete hex escape")
+		}
+		digits = p.pattern[p.pos : p.pos+n]
+		p.pos += n
+	}
+
+	if digits == "" || len(digits) > 8 {
+		return 0, p.errorf(start, "invalid hex escape %q", digits)
+	}
+
+	var char rune
+	for i := 0; i < len(digits); i++ {
+		d := hexDigit(digits[i])
+		if d < 0 {
+			return 0, p.errorf(start, "invalid hex escape %q", digits)
+		}
+		char = char*16 + rune(d)
+	}
+
+	if !utf8.ValidRune(char) {
+		return 0, p.errorf(start, "hex escape %q is not a valid code point", digits)
+	}
+	return char, nil
+}
+
+// hexDigit returns the value of a hexadecimal digit, or -1 if c is not one.
+func hexDigit(c byte) int {
+	switch {
+	case c >= '0' && c <= '9':
+		return int(c - '0')
+	case c >= 'a' && c <= 'f':
+		return int(c-'a') + 10
+	case c >= 'A' && c <= 'F':
+		return int(c-'A') + 10
+	default:
+		return -1
+	}
+}
+
+// parseUnicodeClass parses the name following \p or \P, either a single letter as in \pL
+// or a braced name as in \p{Greek}. A name starting with '^' negates the class.
+func (p *parser) parseUnicodeClass(negated bool, start int) (*CharClass, error) {
+	if p.done() {
+		return nil, p.errorf(start, "missing Unicode class name")
+	}
+
+	var name string
+	if p.peek() == LeftBrace {
+		end := strings.IndexByte(p.pattern[p.pos:], RightBrace)
+		if end == -1 {
+			return nil, p.errorf(start, "missing closing } for Unicode class")
+		}
+		name = p.pattern[p.pos+1 : p.pos+end]
+		p.pos += end + 1
+	} else {
+		name = string(p.nextRune())
+	}
+
+	if strings.HasPrefix(name, "^") {
+		negated = !negated
+		name = name[1:]
+	}
+
+	table := unicodeTable(name)
+	if table == nil {
+		return nil, p.errorf(start, "unknown Unicode class %q", name)
+	}
+	return &CharClass{Tables: []*unicode.RangeTable{table}, Negated: negated}, nil
+}
+
+// lookPrefixes maps the syntax that opens a lookaround group to its kind.
+var lookPrefixes = []struct {
+	prefix string
+	kind   LookKind
+}{
+	{"?=", LookAhead},
+	{"?!", LookAheadNot},
+	{"?<=", LookBehind},
+	{"?<!", LookBehindNot},
+}
+
+// parseGroup parses a parenthesised group starting at the current '('.
+// Plain groups and (?P<name>...) groups capture, (?:...) groups only group, and
+// (?=...), (?!...), (?<=...) and (?<!...) groups are lookaround assertions.
+// (?i) sets flags up to the end of the enclosing group, while (?i:...) only groups
+// and sets them within itself.
+func (p *parser) parseGroup() (*Node, error) {
+	start := p.pos
+	p.pos++
+
+	saved := p.flags
+	defer func() { p.flags = saved }()
+
+	if p.pos+1 < len(p.pattern) && p.peek() == '?' && strings.IndexByte(inlineFlags, p.pattern[p.pos+1]) != -1 {
+		closed, err := p.parseFlags(start)
+		if err != nil {
+			return nil, err
+		}
+		if closed {
+			saved = p.flags
+			return &Node{Kind: NodeEmpty}, nil
+		}
+		return p.parseGroupBody(nil, start)
+	}
+
+	for _, look := range lookPrefixes {
+		if strings.HasPrefix(p.pattern[p.pos:], look.prefix) {
+			p.pos += len(look.prefix)
+			return p.parseGroupBody(&Node{Kind: NodeLook, Look: look.kind}, start)
+		}
+	}
+
+	capture := true
+	name := ""
+
+	switch {
+	case strings.HasPrefix(p.pattern[p.pos:], "?:"):
+		capture = false
+		p.pos += 2
+
+	case strings.HasPrefix(p.pattern[p.pos:], "?P<"):
+		p.pos += 3
+		end := strings.IndexByte(p.pattern[p.pos:], '>')
+		if end == -1 {
+			return nil, p.errorf(start, "missing closing > for group name")
+		}
+		name = p.pattern[p.pos : p.pos+end]
+		if err := p.checkGroupName(name, start); err != nil {
+			return nil, err
+		}
+		p.pos += end + 1
+
+	case strings.HasPrefix(p.pattern[p.pos:], "?"):
+		return nil, p.errorf(start, "unknown group flag")
+	}
+
+	var group *Node
+	if capture {
+		p.ncap++
+		p.names = append(p.names, name)
+		group = &Node{Kind: NodeCapture, Index: p.ncap, Name: name}
+	}
+	return p.parseGroupBody(group, start)
+}
+
+// inlineFlags holds the characters that can start the flags of an inline flag group.
+const inlineFlags = "ims-"
+
+// parseFlags parses the flags of an inline flag group such as (?i), (?m-s) or (?s:...),
+// starting at the '?', and applies them to p.flags. It reports whether the group ended
+// with ')' rather than continuing with ':'.
+func (p *parser) parseFlags(start int) (bool, error) {
+	p.pos++
+	flags := p.flags
+	negated, empty := false, true
+
+	for !p.done() {
+		c := p.peek()
+		p.pos++
+
+		switch c {
+		case 'i':
+			flags.CaseInsensitive = !negated
+		case 'm':
+			flags.Multiline = !negated
+		case 's':
+			flags.DotAll = !negated
+		case '-':
+			if negated {
+				return false, p.errorf(p.pos-1, "repeated - in group flags")
+			}
+			negated, empty = true, true
+			continue
+		case RightParen, ':':
+			if empty {
+				return false, p.errorf(p.pos-1, "missing flag in group flags")
+			}
+			p.flags = flags
+			return c == RightParen, nil
+		default:
+			return false, p.errorf(p.pos-1, "unknown group flag %q", c)
+		}
+		empty = false
+	}
+	return false, p.errorf(start, "missing closing ) for group")
+}
+
+// parseGroupBody parses the contents of a group up to and including its closing ')'.
+// The contents become the only child of group, or are returned as they are if group is nil.
+func (p *parser) parseGroupBody(group *Node, start int) (*Node, error) {
+	node, err := p.parseAlternation()
+	if err != nil {
+		return nil, err
+	}
+
+	if p.done() || p.peek() != RightParen {
+		return nil, p.errorf(start, "missing closing ) for group")
+	}
+	p.pos++
+
+	if group == nil {
+		return node, nil
+	}
+	group.Children = []*Node{node}
+	return group, nil
+}
+
+// checkGroupName reports an error if name is not a valid, unused capture group name.
+func (p *parser) checkGroupName(name string, offset int) error {
+	if name == "" {
+		return p.errorf(offset, "empty group name")
+	}
+
+	for _, c := range name {
+		if !(c == '_' || unicode.IsLetter(c) || unicode.IsDigit(c)) {
+			return p.errorf(offset, "invalid group name %q", name)
+		}
+	}
+
+	for _, existing := range p.names {
+		if existing == name {
+			return p.errorf(offset, "duplicate group name %q", name)
+		}
+	}
+	return nil
+}
+
+// parseCharClass parses a bracket expression starting at the current '['.
+// Members can be single characters, ranges such as a-z, escaped characters such as \]
+// or \-, escape classes such as \d, and POSIX classes such as [:alpha:] or their
+// negations such as [:^alpha:]. A ']' right after the opening '[' or '[^' (or '[!' in a
+// glob) is a literal, as is a '-' at the start or end.
+func (p *parser) parseCharClass() (*Node, error) {
+	start := p.pos
+	p.pos++
+
+	class := &CharClass{}
+	if !p.done() && (p.peek() == NotInClass || p.glob && p.peek() == GlobNegateSet) {
+		class.Negated = true
+		p.pos++
+	}
+
+	for first := true; ; first = false {
+		if p.done() {
+			return nil, p.errorf(start, "missing closing ] for character class")
+		}
+
+		if p.peek() == RightBracket && !first {
+			p.pos++
+			break
+		}
+
+		if strings.HasPrefix(p.pattern[p.pos:], "[:") {
+			if end := strings.Index(p.pattern[p.pos+2:], ":]"); end != -1 {
+				name := p.pattern[p.pos+2 : p.pos+2+end]
+				negated := strings.HasPrefix(name, "^")
+				ranges, ok := posixClasses[strings.TrimPrefix(name, "^")]
+				if !ok {
+					return nil, p.errorf(p.pos, "unknown POSIX class [:%s:]", name)
+				}
+				p.pos += end + 4
+
+				if !negated {
+					class.Ranges = append(class.Ranges, ranges...)
+					continue
+				}
+				member := &CharClass{Ranges: ranges, Negated: true}
+				if p.flags.CaseInsensitive {
+					member = member.folded()
+				}
+				class.merge(member)
+				continue
+			}
+		}
+
+		memberStart := p.pos
+		lo, member, err := p.parseClassChar()
+		if err != nil {
+			return nil, err
+		}
+		if member != nil {
+			if p.flags.CaseInsensitive {
+				member = member.folded()
+			}
+			class.merge(member)
+			continue
+		}
+
+		hi := lo
+		if p.pos+1 < len(p.pattern) && p.peek() == '-' && p.pattern[p.pos+1] != RightBracket {
+			p.pos++
+
+			hi, member, err = p.parseClassChar()
+			if err != nil {
+				return nil, err
+			}
+			if member != nil || hi < lo {
+				return nil, p.errorf(memberStart, "invalid character class range %s", p.pattern[memberStart:p.pos])
+			}
+		}
+		class.Ranges = append(class.Ranges, RuneRange{Lo: lo, Hi: hi})
+	}
+
+	class.Fold = p.flags.CaseInsensitive
+	return &Node{Kind: NodeCharClass, Class: class, Text: p.pattern[start:p.pos]}, nil
+}
+
+// parseClassChar parses a single member of a bracket expression. It returns either
+// the character, or the class for an escape class such as \d.
+func (p *parser) parseClassChar() (rune, *CharClass, error) {
+	if p.peek() == Backslash {
+		return p.parseEscape()
+	}
+	return p.nextRune(), nil, nil
+}
+
+// nextRune decodes the UTF-8 character at the current position and moves past it.
+func (p *parser) nextRune() rune {
+	r, width := utf8.DecodeRuneInString(p.pattern[p.pos:])
+	p.pos += width
+	return r
+}
+
+// peek returns the byte at the current position.
+func (p *parser) peek() byte {
+	return p.pattern[p.pos]
+}
+
+// done reports whether the whole pattern has been consumed.
+func (p *parser) done() bool {
+	return p.pos >= len(p.pattern)
+}
@@ -0,0 +1,244 @@
+package matcher
+
+import "unicode/utf8"
+
+// thread is a single NFA thread: a program counter and the capture slots recorded so far.
+// Capture slices are never modified in place once shared, so threads can alias them.
+type thread struct {
+	pc   int
+	caps []int
+}
+
+// threadList is an ordered set of threads keyed by program counter. It is a sparse
+// set, so membership checks and clearing are constant time regardless of program size.
+type threadList struct {
+	sparse []int
+	dense  []thread
+}
+
+func newThreadList(size int) *threadList {
+	return &threadList{
+		sparse: make([]int, size),
+		dense:  make([]thread, 0, size),
+	}
+}
+
+func (l *threadList) contains(pc int) bool {
+	i := l.sparse[pc]
+	return i < len(l.dense) && l.dense[i].pc == pc
+}
+
+func (l *threadList) insert(pc int) int {
+	l.sparse[pc] = len(l.dense)
+	l.dense = append(l.dense, thread{pc: pc})
+	return len(l.dense) - 1
+}
+
+func (l *threadList) clear() {
+	l.dense = l.dense[:0]
+}
+
+// pikeVM simulates a Prog over an input line by advancing every live thread one
+// character at a time. Threads are kept in priority order, which yields leftmost-first
+// semantics, and each program counter is visited at most once per position, which
+// keeps matching linear in the length of the line. A pikeVM is not safe for concurrent
+// use, but it can be reset and reused for any number of searches of its program.
+type pikeVM struct {
+	prog    *Prog
+	line    []byte
+	ncap    int
+	clist   *threadList
+	nlist   *threadList
+	matched []int
+	budget  *budget
+}
+
+func newPikeVM(prog *Prog) *pikeVM {
+	return &pikeVM{
+		prog:  prog,
+		clist: newThreadList(len(prog.Insts)),
+		nlist: newThreadList(len(prog.Insts)),
+	}
+}
+
+// reset prepares the VM for a search of line that records ncap capture slots, keeping
+// the thread lists it has already allocated.
+func (m *pikeVM) reset(line []byte, ncap int, budget *budget) {
+	m.line = line
+	m.ncap = ncap
+	m.budget = budget
+	m.matched = nil
+	m.clist.clear()
+	m.nlist.clear()
+}
+
+// run searches for the leftmost-first match starting at or after pos.
+//
+// Parameters:
+// - pos: The offset in the line at which the search starts.
+//
+// Returns:
+// - []int: The capture slots of the match, or nil if there is no match.
+func (m *pikeVM) run(pos int) []int {
+	for {
+		if m.matched == nil {
+			caps := make([]int, m.slots())
+			for i := range caps {
+				caps[i] = -1
+			}
+			m.add(m.clist, m.prog.Start, pos, caps)
+		}
+
+		if len(m.clist.dense) == 0 {
+			break
+		}
+
+		char, width := m.step(pos)
+		m.advance(pos, char, width)
+		if m.budget.exceeded() != nil {
+			return nil
+		}
+
+		if pos >= len(m.line) {
+			break
+		}
+		pos += width
+		m.clist, m.nlist = m.nlist, m.clist
+		m.nlist.clear()
+	}
+	if m.matched == nil {
+		return nil
+	}
+	return m.matched[:m.ncap]
+}
+
+// slots returns the number of slots each thread carries: the capture slots the caller
+// asked for and, if the program has any, every slot up to its loop registers, which
+// InstProgress needs to tell an empty loop iteration from one that made progress.
+func (m *pikeVM) slots() int {
+	if m.prog.NumSlots > m.prog.NumCap {
+		return m.prog.NumSlots
+	}
+	return m.ncap
+}
+
+// step decodes the character at pos and returns it with its width in bytes.
+// The width is 0 at the end of the line.
+func (m *pikeVM) step(pos int) (rune, int) {
+	return decodeRune(m.line, pos)
+}
+
+// advance runs every thread in the current list against the character at pos and
+// queues the survivors on the next list. A thread that reaches InstMatch records the
+// match and cuts off all lower-priority threads.
+func (m *pikeVM) advance(pos int, char rune, width int) {
+	for _, t := range m.clist.dense {
+		if !m.budget.spend() {
+			return
+		}
+		inst := &m.prog.Insts[t.pc]
+
+		switch inst.Op {
+		case InstMatch:
+			m.matched = t.caps
+			return
+		case InstChar:
+			if width > 0 && inst.Node.matchChar(char) {
+				m.add(m.nlist, inst.Out, pos+width, t.caps)
+			}
+		}
+	}
+}
+
+// add follows jumps, splits, assertions and saves from pc and adds the resulting
+// threads to the list in priority order.
+func (m *pikeVM) add(l *threadList, pc, pos int, caps []int) {
+	if l.contains(pc) {
+		return
+	}
+	idx := l.insert(pc)
+	inst := &m.prog.Insts[pc]
+
+	switch inst.Op {
+	case InstJmp:
+		m.add(l, inst.Out, pos, caps)
+
+	case InstSplit:
+		m.add(l, inst.Out, pos, caps)
+		m.add(l, inst.Arg, pos, caps)
+
+	case InstAssert:
+		if assertAt(inst.Assert, m.line, pos) {
+			m.add(l, inst.Out, pos, caps)
+		}
+
+	case InstLook:
+		if ok, _ := lookAt(m.prog, m.line, inst, pos, nil, m.budget); ok {
+			m.add(l, inst.Out, pos, caps)
+		}
+
+	case InstProgress:
+		if inst.Arg >= len(caps) || caps[inst.Arg] != pos {
+			m.add(l, inst.Out, pos, caps)
+		}
+
+	case InstSave:
+		if inst.Arg < m.ncap || inst.Arg >= m.prog.NumCap && inst.Arg < len(caps) {
+			saved := make([]int, len(caps))
+			copy(saved, caps)
+			saved[inst.Arg] = pos
+			caps = saved
+		}
+		m.add(l, inst.Out, pos, caps)
+
+	default:
+		l.dense[idx].caps = caps
+	}
+}
+
+// decodeRune decodes the UTF-8 character at pos and returns it with its width in bytes.
+// An invalid byte decodes to utf8.RuneError with width 1, and the width is 0 at the
+// end of the line.
+func decodeRune(line []byte, pos int) (rune, int) {
+	if pos >= len(line) {
+		return utf8.RuneError, 0
+	}
+	if c := line[pos]; c < utf8.RuneSelf {
+		return rune(c), 1
+	}
+	return utf8.DecodeRune(line[pos:])
+}
+
+// assertAt reports whether a zero-width assertion holds at pos in line.
+func assertAt(kind AssertKind, line []byte, pos int) bool {
+	switch kind {
+	case AssertBeginLine:
+		return pos == 0 || line[pos-1] == '\n'
+	case AssertEndLine:
+		return pos == len(line) || line[pos] == '\n'
+	case AssertBeginText:
+		return pos == 0
+	case AssertEndText:
+		return pos == len(line)
+	case AssertWordBoundary:
+		return atWordBoundary(line, pos)
+	case AssertNotWordBoundary:
+		return !atWordBoundary(line, pos)
+	default:
+		return false
+	}
+}
+
+// atWordBoundary reports whether pos lies between a word character and a non-word
+// character, treating the edges of the line as non-word characters.
+func atWordBoundary(line []byte, pos int) bool {
+	before, after := false, false
+	if pos > 0 {
+		char, _ := utf8.DecodeLastRune(line[:pos])
+		before = isWordChar(char)
+	}
+	if char, width := decodeRune(line, pos); width > 0 {
+		after = isWordChar(char)
+	}
+	return before != after
+}
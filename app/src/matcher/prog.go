@@ -0,0 +1,402 @@
+package matcher
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errProgTooLarge is returned by compileProg for patterns that would expand into more
+// than maxProgSize instructions.
+var errProgTooLarge = errors.New("expression too large")
+
+// InstOp is the opcode of a single program instruction.
+type InstOp uint8
+
+const (
+	InstMatch    InstOp = iota // the whole pattern has matched
+	InstChar                   // consume one character accepted by Node
+	InstSplit                  // continue at Out, then at Arg with lower priority
+	InstJmp                    // continue at Out
+	InstAssert                 // zero-width assertion described by Assert
+	InstSave                   // record the current position in capture slot Arg
+	InstBackref                // consume the text last captured by group Arg
+	InstProgress               // fail unless the position moved since slot Arg was saved
+	InstLook                   // lookaround whose body starts at instruction Arg
+	InstAtomic                 // run the body at instruction Arg once and continue from where it ended
+)
+
+// AssertKind identifies the zero-width condition checked by an InstAssert instruction.
+type AssertKind uint8
+
+const (
+	AssertBeginLine       AssertKind = iota // '^' in multiline mode
+	AssertEndLine                           // '$' in multiline mode
+	AssertBeginText                         // '^'
+	AssertEndText                           // '$'
+	AssertWordBoundary                      // \b
+	AssertNotWordBoundary                   // \B
+)
+
+// Inst is a single instruction of a compiled program.
+type Inst struct {
+	Op     InstOp
+	Out    int        // next instruction
+	Arg    int        // InstSplit: alternative instruction, InstSave/InstProgress: slot, InstBackref: group, InstLook/InstAtomic: body
+	Node   *Node      // InstChar: the single-character node to test against, InstBackref: the backreference, InstLook/InstAtomic: the body
+	Assert AssertKind // InstAssert
+	Look   LookKind   // InstLook
+
+	// InstLook: the fewest and the most bytes the body can match, the most being -1
+	// when there is no bound. A lookbehind only tries the starts this leaves possible.
+	Min, Max int
+}
+
+// Prog is a pattern compiled into a Thompson NFA. Execution starts at Start and
+// succeeds when an InstMatch instruction is reached.
+//
+// The bodies of lookaround assertions and possessive quantifiers are laid out after
+// the main program, each ending in its own InstMatch, and are only entered through
+// an InstLook or InstAtomic.
+//
+// The first NumCap slots hold capture group offsets. Slots beyond that, up to
+// NumSlots, are loop registers used by InstProgress to stop loops whose body
+// matched the empty string.
+type Prog struct {
+	Insts     []Inst
+	Start     int
+	NumCap    int
+	NumSlots  int
+	Backtrack bool // the program uses backreferences, possessive quantifiers or groups inside lookarounds and must run on the backtracker
+}
+
+// compileProg compiles a parsed pattern into a program.
+// The body is wrapped in saves of slots 0 and 1 so every match reports its span;
+// capture group n records its span in slots 2n and 2n+1.
+//
+// Parameters:
+// - root: The root node of the parsed pattern.
+// - ncap: The number of capture groups in the pattern.
+//
+// Returns:
+// - *Prog: The compiled program.
+// - error: errProgTooLarge if the program would have more than maxProgSize instructions.
+func compileProg(root *Node, ncap int) (*Prog, error) {
+	numCap := 2 * (ncap + 1)
+	c := &compiler{prog: &Prog{NumCap: numCap, NumSlots: numCap}}
+
+	c.emit(Inst{Op: InstSave, Arg: 0})
+	c.compile(root)
+	c.emit(Inst{Op: InstSave, Arg: 1})
+	c.emit(Inst{Op: InstMatch})
+
+	for len(c.bodies) > 0 {
+		pc := c.bodies[0]
+		c.bodies = c.bodies[1:]
+
+		c.prog.Insts[pc].Arg = c.pc()
+		c.compile(c.prog.Insts[pc].Node)
+		c.emit(Inst{Op: InstMatch})
+	}
+
+	if c.tooLarge {
+		return nil, errProgTooLarge
+	}
+	return c.prog, nil
+}
+
+// compiler emits instructions for a tree of Nodes. Instructions are laid out in
+// order, so unless a jump says otherwise execution falls through to the next one.
+type compiler struct {
+	prog   *Prog
+	bodies []int // InstLook and InstAtomic instructions whose bodies still have to be emitted
+
+	// tooLarge is set once the program passes maxProgSize instructions. From then on
+	// compile emits nothing, so a huge repetition stops expanding right away.
+	tooLarge bool
+}
+
+// newSlot allocates a loop register and returns its slot number.
+func (c *compiler) newSlot() int {
+	c.prog.NumSlots++
+	return c.prog.NumSlots - 1
+}
+
+// emit appends an instruction that falls through to the next one and returns its index.
+func (c *compiler) emit(inst Inst) int {
+	pc := len(c.prog.Insts)
+	if pc >= maxProgSize {
+		c.tooLarge = true
+	}
+	inst.Out = pc + 1
+	c.prog.Insts = append(c.prog.Insts, inst)
+	return pc
+}
+
+// pc returns the index of the next instruction to be emitted.
+func (c *compiler) pc() int {
+	return len(c.prog.Insts)
+}
+
+// compile emits the instructions for a node.
+func (c *compiler) compile(n *Node) {
+	if c.tooLarge {
+		return
+	}
+
+	switch n.Kind {
+	case NodeEmpty:
+
+	case NodeLiteral, NodeAnyChar, NodeAnyCharNotNL, NodeEscape, NodeCharClass:
+		c.emit(Inst{Op: InstChar, Node: n})
+
+	case NodeBeginLine:
+		c.emit(Inst{Op: InstAssert, Assert: AssertBeginLine})
+
+	case NodeEndLine:
+		c.emit(Inst{Op: InstAssert, Assert: AssertEndLine})
+
+	case NodeBeginText:
+		c.emit(Inst{Op: InstAssert, Assert: AssertBeginText})
+
+	case NodeEndText:
+		c.emit(Inst{Op: InstAssert, Assert: AssertEndText})
+
+	case NodeWordBoundary:
+		c.emit(Inst{Op: InstAssert, Assert: AssertWordBoundary})
+
+	case NodeNotWordBoundary:
+		c.emit(Inst{Op: InstAssert, Assert: AssertNotWordBoundary})
+
+	case NodeConcat:
+		for _, child := range n.Children {
+			c.compile(child)
+		}
+
+	case NodeAlternate:
+		c.compileAlternate(n.Children)
+
+	case NodeRepeat:
+		if n.Mode != RepeatPossessive {
+			c.compileRepeat(n)
+			break
+		}
+
+		greedy := *n
+		greedy.Mode = RepeatGreedy
+		pc := c.emit(Inst{Op: InstAtomic, Node: &greedy})
+		c.bodies = append(c.bodies, pc)
+		c.prog.Backtrack = true
+
+	case NodeCapture:
+		c.emit(Inst{Op: InstSave, Arg: 2 * n.Index})
+		c.compile(n.Children[0])
+		c.emit(Inst{Op: InstSave, Arg: 2*n.Index + 1})
+
+	case NodeBackref:
+		c.emit(Inst{Op: InstBackref, Arg: n.Index, Node: n})
+		c.prog.Backtrack = true
+
+	case NodeLook:
+		lo, hi := n.Children[0].width()
+		pc := c.emit(Inst{Op: InstLook, Look: n.Look, Node: n.Children[0], Min: lo, Max: hi})
+		c.bodies = append(c.bodies, pc)
+		if hasCapture(n.Children[0]) {
+			c.prog.Backtrack = true
+		}
+	}
+}
+
+// hasCapture reports whether the node or any node below it is a capture group.
+func hasCapture(n *Node) bool {
+	if n.Kind == NodeCapture {
+		return true
+	}
+	for _, child := range n.Children {
+		if hasCapture(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileAlternate emits
+//
+//	    split L1, next
+//	L1: <alt 1>
+//	    jmp end
+//	next:
+//	    ...
+//	    <alt n>
+//	end:
+//
+// so earlier alternatives are preferred over later ones.
+func (c *compiler) compileAlternate(alts []*Node) {
+	var jumps []int
+
+	for i, alt := range alts {
+		if i == len(alts)-1 {
+			c.compile(alt)
+			break
+		}
+
+		split := c.emit(Inst{Op: InstSplit})
+		c.compile(alt)
+		jumps = append(jumps, c.emit(Inst{Op: InstJmp}))
+		c.prog.Insts[split].Arg = c.pc()
+	}
+
+	for _, j := range jumps {
+		c.prog.Insts[j].Out = c.pc()
+	}
+}
+
+// compileRepeat emits the child of a repeat node Min times followed by either a loop
+// (when Max is unbounded) or Max-Min optional copies. For a greedy quantifier every
+// split prefers another repetition; for a lazy one every split prefers to stop.
+//
+// Unbounded repeats of a child that can match the empty string are emitted by
+// compileNullableLoop instead.
+func (c *compiler) compileRepeat(n *Node) {
+	child := n.Children[0]
+
+	if n.Max == -1 && child.nullable() {
+		c.compileNullableLoop(n)
+		return
+	}
+
+	for i := 0; i < n.Min; i++ {
+		c.compile(child)
+	}
+
+	if n.Max == -1 {
+		loop := c.emit(Inst{Op: InstSplit})
+		c.compile(child)
+		jmp := c.emit(Inst{Op: InstJmp})
+		c.prog.Insts[jmp].Out = loop
+		c.prog.Insts[loop].Arg = c.pc()
+		c.setPreference(loop, n.Mode)
+		return
+	}
+
+	var splits []int
+	for i := n.Min; i < n.Max; i++ {
+		splits = append(splits, c.emit(Inst{Op: InstSplit}))
+		c.compile(child)
+	}
+
+	for _, s := range splits {
+		c.prog.Insts[s].Arg = c.pc()
+		c.setPreference(s, n.Mode)
+	}
+}
+
+// compileNullableLoop emits an unbounded repeat of a child that can match the empty
+// string. All but the last of the Min required copies are emitted as usual, and the
+// rest as
+//
+//	    split L1, end      (only when Min is 0)
+//	L1: save slot
+//	    <child>
+//	    split L2, end
+//	L2: progress slot
+//	    jmp L1
+//	end:
+//
+// which is the layout regexp uses for x+ and (x+)?. An iteration that matched the
+// empty string may still leave the loop, keeping its captures, but never starts
+// another one, so the backtracker cannot spin forever on patterns like (a*)*.
+func (c *compiler) compileNullableLoop(n *Node) {
+	child := n.Children[0]
+
+	for i := 0; i < n.Min-1; i++ {
+		c.compile(child)
+	}
+
+	var splits []int
+	if n.Min == 0 {
+		splits = append(splits, c.emit(Inst{Op: InstSplit}))
+	}
+
+	slot := c.newSlot()
+	body := c.emit(Inst{Op: InstSave, Arg: slot})
+	c.compile(child)
+	splits = append(splits, c.emit(Inst{Op: InstSplit}))
+	c.emit(Inst{Op: InstProgress, Arg: slot})
+	jmp := c.emit(Inst{Op: InstJmp})
+	c.prog.Insts[jmp].Out = body
+
+	for _, s := range splits {
+		c.prog.Insts[s].Arg = c.pc()
+		c.setPreference(s, n.Mode)
+	}
+}
+
+// setPreference swaps the branches of a repetition split for lazy quantifiers, so that
+// leaving the repetition is tried before another iteration.
+func (c *compiler) setPreference(split int, mode RepeatMode) {
+	if mode == RepeatLazy {
+		inst := &c.prog.Insts[split]
+		inst.Out, inst.Arg = inst.Arg, inst.Out
+	}
+}
+
+// String returns a human-readable listing of the program, one instruction per line.
+func (p *Prog) String() string {
+	var sb strings.Builder
+	for pc, inst := range p.Insts {
+		fmt.Fprintf(&sb, "%3d  %s\n", pc, inst.String())
+	}
+	return sb.String()
+}
+
+// String returns a human-readable form of the instruction.
+func (i Inst) String() string {
+	switch i.Op {
+	case InstMatch:
+		return "match"
+	case InstChar:
+		return fmt.Sprintf("char %s -> %d", i.Node.charString(), i.Out)
+	case InstSplit:
+		return fmt.Sprintf("split %d, %d", i.Out, i.Arg)
+	case InstJmp:
+		return fmt.Sprintf("jmp %d", i.Out)
+	case InstAssert:
+		return fmt.Sprintf("assert %s -> %d", i.Assert.String(), i.Out)
+	case InstSave:
+		return fmt.Sprintf("save %d -> %d", i.Arg, i.Out)
+	case InstBackref:
+		if i.Node.Fold {
+			return fmt.Sprintf("backref (?i)\\%d -> %d", i.Arg, i.Out)
+		}
+		return fmt.Sprintf("backref \\%d -> %d", i.Arg, i.Out)
+	case InstProgress:
+		return fmt.Sprintf("progress %d -> %d", i.Arg, i.Out)
+	case InstLook:
+		return fmt.Sprintf("look %s) at %d -> %d", i.Look.String(), i.Arg, i.Out)
+	case InstAtomic:
+		return fmt.Sprintf("atomic at %d -> %d", i.Arg, i.Out)
+	default:
+		return "unknown"
+	}
+}
+
+// String returns the pattern syntax of the assertion.
+func (a AssertKind) String() string {
+	switch a {
+	case AssertBeginLine:
+		return "(?m)^"
+	case AssertEndLine:
+		return "(?m)$"
+	case AssertBeginText:
+		return "^"
+	case AssertEndText:
+		return "$"
+	case AssertWordBoundary:
+		return "\\b"
+	case AssertNotWordBoundary:
+		return "\\B"
+	default:
+		return "?"
+	}
+}
@@ -0,0 +1,453 @@
+package matcher
+
+import (
+	"bytes"
+	"context"
+	"sync"
+)
+
+// Regexp is a compiled pattern. It is parsed once by Compile and can then be
+// matched against any number of lines. A Regexp is safe for concurrent use.
+//
+// Patterns and lines are treated as UTF-8: '.', classes and escapes match whole
+// characters, while every offset reported is a byte offset into the line.
+type Regexp struct {
+	expr        string
+	root        *Node
+	prog        *Prog
+	numSubexp   int
+	subexpNames []string
+	dfas        *sync.Pool // lazy DFAs for the program, nil if it cannot run on one
+	vms         *sync.Pool // Pike VMs for the program, nil if it needs the backtracker
+	prefix      []byte     // literal text every match starts with, if any
+	required    [][]byte   // every match contains one of these literals; nil if unknown
+	maxSteps    int
+}
+
+// CompileOptions holds the flags a pattern starts with and the limits its searches
+// run under. Inline flags such as (?i) or (?-s) in the pattern override the flags from
+// where they appear.
+type CompileOptions struct {
+	CaseInsensitive bool // letters match their case variants, as with (?i)
+	Multiline       bool // '^' and '$' also match next to '\n', as with (?m)
+	DotAll          bool // '.' also matches '\n', as with (?s)
+
+	// MaxSteps caps the instructions a single search may execute, guarding against
+	// patterns whose backreferences or lookarounds take exponential time. A search
+	// that runs out finds no match, and the Context methods report
+	// ErrMatchBudgetExceeded. Zero means no limit. Only the built-in engine counts
+	// steps. The pcre engine can only limit time, so its searches time out after
+	// 100ns per step instead, and the re2 engine runs in linear time anyway.
+	MaxSteps int
+}
+
+// Compile parses a pattern and returns a Regexp that can be used to match lines against it.
+//
+// Parameters:
+// - pattern: The pattern string to be compiled.
+//
+// Returns:
+// - *Regexp: The compiled pattern.
+// - error: A *SyntaxError describing the problem if the pattern is malformed.
+func Compile(pattern string) (*Regexp, error) {
+	return CompileWithOptions(pattern, CompileOptions{})
+}
+
+// CompileWithOptions is like Compile but starts the pattern with the given flags set.
+//
+// Parameters:
+// - pattern: The pattern string to be compiled.
+// - opts: The flags in effect at the start of the pattern.
+//
+// Returns:
+// - *Regexp: The compiled pattern.
+// - error: A *SyntaxError describing the problem if the pattern is malformed.
+func CompileWithOptions(pattern string, opts CompileOptions) (*Regexp, error) {
+	p, root, err := parse(pattern, opts)
+	if err != nil {
+		return nil, err
+	}
+	return newRegexp(pattern, root, p, opts)
+}
+
+// newRegexp compiles a parsed pattern and prepares everything its searches need. It
+// returns a *SyntaxError if the program would be too large.
+func newRegexp(pattern string, root *Node, p *parser, opts CompileOptions) (*Regexp, error) {
+	prog, err := compileProg(root, p.ncap)
+	if err != nil {
+		return nil, p.errorf(0, "%v", err)
+	}
+
+	re := &Regexp{
+		expr:        pattern,
+		root:        root,
+		prog:        prog,
+		numSubexp:   p.ncap,
+		subexpNames: p.names,
+		maxSteps:    opts.MaxSteps,
+	}
+	re.prefix = literalPrefix(root)
+	for _, lit := range literals(root).required {
+		re.required = append(re.required, []byte(lit))
+	}
+	if canUseDFA(re.prog) {
+		re.dfas = &sync.Pool{New: func() any { return newDFA(re.prog) }}
+	}
+	if !re.prog.Backtrack {
+		re.vms = &sync.Pool{New: func() any { return newPikeVM(re.prog) }}
+	}
+	return re, nil
+}
+
+// MustCompile is like Compile but panics if the pattern cannot be parsed.
+func MustCompile(pattern string) *Regexp {
+	re, err := Compile(pattern)
+	if err != nil {
+		panic("matcher: Compile(" + pattern + "): " + err.Error())
+	}
+	return re
+}
+
+// String returns the source pattern used to compile the Regexp.
+func (re *Regexp) String() string {
+	return re.expr
+}
+
+// Syntax returns the root of the abstract syntax tree the pattern was parsed into.
+// The tree is shared by every search and must not be modified.
+func (re *Regexp) Syntax() *Node {
+	return re.root
+}
+
+// Prog returns the program the pattern was compiled into. The program is shared by
+// every search and must not be modified.
+func (re *Regexp) Prog() *Prog {
+	return re.prog
+}
+
+// NumSubexp returns the number of capture groups in the pattern.
+func (re *Regexp) NumSubexp() int {
+	return re.numSubexp
+}
+
+// SubexpNames returns the names of the capture groups. The name of group i is at
+// index i; index 0 stands for the whole match and unnamed groups have empty names.
+func (re *Regexp) SubexpNames() []string {
+	return re.subexpNames
+}
+
+// SubexpIndex returns the number of the capture group with the given name,
+// or -1 if there is no such group.
+func (re *Regexp) SubexpIndex(name string) int {
+	if name == "" {
+		return -1
+	}
+	for i, n := range re.subexpNames {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Match reports whether the line contains any match of the pattern.
+func (re *Regexp) Match(line []byte) bool {
+	matched, _ := re.match(line, re.budget(nil))
+	return matched
+}
+
+// MatchContext is like Match but gives up once ctx is done or the search has taken
+// more than MaxSteps steps, returning an error wrapping ErrMatchBudgetExceeded, and
+// also wrapping the context's error in the first case.
+//
+// Parameters:
+// - ctx: The context bounding the search.
+// - line: The byte slice representing the line to be checked.
+//
+// Returns:
+// - bool: True if the line contains a match, false otherwise.
+// - error: An error wrapping ErrMatchBudgetExceeded if the search gave up.
+func (re *Regexp) MatchContext(ctx context.Context, line []byte) (bool, error) {
+	return re.match(line, re.budget(ctx))
+}
+
+// match reports whether the line contains a match, within the budget.
+func (re *Regexp) match(line []byte, b *budget) (bool, error) {
+	if !re.hasRequired(line) {
+		return false, nil
+	}
+	if matched, ok := re.dfaMatch(line, b); ok {
+		return matched, b.exceeded()
+	}
+	return re.run(line, 0, 2, b) != nil, b.exceeded()
+}
+
+// MatchIndex returns the index of the first character in the line that matches the pattern,
+// or -1 if no match is found.
+func (re *Regexp) MatchIndex(line []byte) int {
+	loc := re.FindIndex(line)
+	if loc == nil {
+		return -1
+	}
+	return loc[0]
+}
+
+// FindIndex returns a two-element slice holding the start and end offsets of the leftmost
+// match in the line, or nil if there is no match.
+func (re *Regexp) FindIndex(line []byte) []int {
+	return re.exec(line, 0, 2, re.budget(nil))
+}
+
+// FindIndexContext is like FindIndex but gives up in the same way as MatchContext.
+func (re *Regexp) FindIndexContext(ctx context.Context, line []byte) ([]int, error) {
+	b := re.budget(ctx)
+	loc := re.exec(line, 0, 2, b)
+	return loc, b.exceeded()
+}
+
+// FindSubmatchIndex returns the start and end offsets of the leftmost match and of
+// every capture group within it. The offsets of group i are at indexes 2*i and 2*i+1;
+// a group that did not take part in the match has offsets -1. It returns nil if
+// there is no match.
+func (re *Regexp) FindSubmatchIndex(line []byte) []int {
+	return re.exec(line, 0, re.prog.NumCap, re.budget(nil))
+}
+
+// FindSubmatch returns the text of the leftmost match and of every capture group
+// within it. A group that did not take part in the match is nil. It returns nil
+// if there is no match.
+func (re *Regexp) FindSubmatch(line []byte) [][]byte {
+	loc := re.FindSubmatchIndex(line)
+	if loc == nil {
+		return nil
+	}
+
+	subs := make([][]byte, len(loc)/2)
+	for i := range subs {
+		if loc[2*i] >= 0 {
+			subs[i] = line[loc[2*i]:loc[2*i+1]]
+		}
+	}
+	return subs
+}
+
+// FindAll returns successive non-overlapping matches of the pattern in the line.
+// If n >= 0, at most n matches are returned. It returns nil if there is no match.
+func (re *Regexp) FindAll(line []byte, n int) [][]byte {
+	var matches [][]byte
+	for _, loc := range re.FindAllIndex(line, n) {
+		matches = append(matches, line[loc[0]:loc[1]])
+	}
+	return matches
+}
+
+// FindAllIndex returns the start and end offsets of successive non-overlapping matches
+// of the pattern in the line. An empty match right after a previous match is skipped,
+// so a* finds "aaa" in "baaac" but not the empty string that follows it.
+// If n >= 0, at most n matches are returned. It returns nil if there is no match.
+func (re *Regexp) FindAllIndex(line []byte, n int) [][]int {
+	return re.allAt(line, n, 2)
+}
+
+// ReplaceAll returns a copy of the line with every match of the pattern replaced by
+// the template. In the template, $1 or ${1} stands for the text of group 1, ${name}
+// for the text of the group with that name, and $$ for a literal '$'. A reference to
+// a group that does not exist or did not take part in the match expands to nothing.
+//
+// Parameters:
+// - line: The byte slice representing the line to be changed.
+// - template: The replacement for each match.
+//
+// Returns:
+// - []byte: The line with the replacements made.
+func (re *Regexp) ReplaceAll(line, template []byte) []byte {
+	var out []byte
+	last := 0
+	for _, loc := range re.allAt(line, -1, re.prog.NumCap) {
+		out = append(out, line[last:loc[0]]...)
+		out = re.expand(out, template, line, loc)
+		last = loc[1]
+	}
+	return append(out, line[last:]...)
+}
+
+// expand appends the template to out, replacing group references with the text they
+// refer to in the match whose capture slots are loc.
+func (re *Regexp) expand(out, template, line []byte, loc []int) []byte {
+	for i := 0; i < len(template); {
+		if template[i] != '$' || i+1 == len(template) {
+			out = append(out, template[i])
+			i++
+			continue
+		}
+
+		group, next := -1, i+1
+		switch c := template[i+1]; {
+		case c == '$':
+			out = append(out, '$')
+			i += 2
+			continue
+
+		case c >= '0' && c <= '9':
+			for next < len(template) && template[next] >= '0' && template[next] <= '9' {
+				next++
+			}
+			group = groupNumber(string(template[i+1 : next]))
+
+		case c == LeftBrace:
+			end := bytes.IndexByte(template[i+2:], RightBrace)
+			if end == -1 {
+				out = append(out, '$')
+				i++
+				continue
+			}
+			ref := string(template[i+2 : i+2+end])
+			next = i + 2 + end + 1
+			if group = groupNumber(ref); group == -1 {
+				group = re.SubexpIndex(ref)
+			}
+
+		default:
+			out = append(out, '$')
+			i++
+			continue
+		}
+
+		if group >= 0 && 2*group+1 < len(loc) && loc[2*group] >= 0 {
+			out = append(out, line[loc[2*group]:loc[2*group+1]]...)
+		}
+		i = next
+	}
+	return out
+}
+
+// groupNumber parses a group number written in a replacement template, returning -1
+// if ref is not a number.
+func groupNumber(ref string) int {
+	n, ok := parseRepeatCount(ref)
+	if !ok {
+		return -1
+	}
+	return n
+}
+
+// Split slices the line into the parts separated by matches of the pattern. Empty
+// matches at the very start or end of the line do not produce empty parts there.
+// If n > 0, at most n parts are returned and the last one holds the rest of the line;
+// if n == 0 the result is nil; if n < 0 all parts are returned. The parts are
+// subslices of line.
+func (re *Regexp) Split(line []byte, n int) [][]byte {
+	if n == 0 {
+		return nil
+	}
+
+	var parts [][]byte
+	last := 0
+	for _, loc := range re.FindAllIndex(line, -1) {
+		if n > 0 && len(parts) == n-1 {
+			break
+		}
+		if loc[0] == loc[1] && (loc[0] == 0 || loc[0] == len(line)) {
+			continue
+		}
+		parts = append(parts, line[last:loc[0]])
+		last = loc[1]
+	}
+	return append(parts, line[last:])
+}
+
+// allAt returns the first ncap capture slots of successive non-overlapping matches,
+// as described by FindAllIndex.
+func (re *Regexp) allAt(line []byte, n, ncap int) [][]int {
+	var matches [][]int
+	prevEnd := -1
+	b := re.budget(nil)
+
+	for pos := 0; pos <= len(line) && (n < 0 || len(matches) < n); {
+		loc := re.exec(line, pos, ncap, b)
+		if loc == nil {
+			break
+		}
+		if loc[1] > loc[0] || loc[0] != prevEnd {
+			matches = append(matches, loc)
+			prevEnd = loc[1]
+		}
+
+		if loc[1] > loc[0] {
+			pos = loc[1]
+		} else if _, width := decodeRune(line, loc[1]); width > 0 {
+			pos = loc[1] + width
+		} else {
+			break
+		}
+	}
+	return matches
+}
+
+// budget returns the budget for one search: MaxSteps steps until ctx is done, where
+// a nil ctx never is.
+func (re *Regexp) budget(ctx context.Context) *budget {
+	return newBudget(ctx, re.maxSteps)
+}
+
+// exec returns the first ncap capture slots of the leftmost match at or after pos.
+// A search from the start of the line first checks for the required literals and asks
+// the lazy DFA whether there is a match at all, so lines without one never reach the
+// slower NFA.
+func (re *Regexp) exec(line []byte, pos, ncap int, b *budget) []int {
+	if pos == 0 {
+		if !re.hasRequired(line) {
+			return nil
+		}
+		if matched, ok := re.dfaMatch(line, b); ok && !matched {
+			return nil
+		}
+	}
+	return re.run(line, pos, ncap, b)
+}
+
+// hasRequired reports whether the line contains one of the literals every match must
+// contain, which bytes.Index checks much faster than any automaton can.
+func (re *Regexp) hasRequired(line []byte) bool {
+	if re.required == nil {
+		return true
+	}
+	for _, lit := range re.required {
+		if bytes.Contains(line, lit) {
+			return true
+		}
+	}
+	return false
+}
+
+// dfaMatch reports whether the line contains a match, using one of the pattern's lazy
+// DFAs. The second result is false if the pattern cannot run on a DFA or the DFA gave up.
+func (re *Regexp) dfaMatch(line []byte, b *budget) (bool, bool) {
+	if re.dfas == nil {
+		return false, false
+	}
+	d := re.dfas.Get().(*dfa)
+	defer re.dfas.Put(d)
+	return d.match(line, b)
+}
+
+// run runs the program on the NFA engine it needs, starting at the first occurrence
+// of the literal prefix if there is one. Programs with backreferences or possessive
+// quantifiers run on the backtracker; everything else runs on the linear-time Pike VM.
+func (re *Regexp) run(line []byte, pos, ncap int, b *budget) []int {
+	if len(re.prefix) > 0 {
+		i := bytes.Index(line[pos:], re.prefix)
+		if i == -1 {
+			return nil
+		}
+		pos += i
+	}
+
+	if re.prog.Backtrack {
+		return newBacktracker(re.prog, line, ncap, b).run(pos)
+	}
+	m := re.vms.Get().(*pikeVM)
+	defer re.vms.Put(m)
+	m.reset(line, ncap, b)
+	return m.run(pos)
+}
@@ -0,0 +1,1001 @@
+package matcher
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		wantErr bool
+	}{
+		{
+			name:    "literal",
+			pattern: "hello",
+			wantErr: false,
+		},
+		{
+			name:    "alternation group",
+			pattern: "(cat|dog)s",
+			wantErr: false,
+		},
+		{
+			name:    "unterminated class",
+			pattern: "[abc",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated group",
+			pattern: "(abc",
+			wantErr: true,
+		},
+		{
+			name:    "unmatched closing paren",
+			pattern: "abc)",
+			wantErr: true,
+		},
+		{
+			name:    "named group",
+			pattern: "(?P<year>\\d\\d\\d\\d)",
+			wantErr: false,
+		},
+		{
+			name:    "duplicate group name",
+			pattern: "(?P<x>a)(?P<x>b)",
+			wantErr: true,
+		},
+		{
+			name:    "empty group name",
+			pattern: "(?P<>a)",
+			wantErr: true,
+		},
+		{
+			name:    "backreference to missing group",
+			pattern: "(a)\\2",
+			wantErr: true,
+		},
+		{
+			name:    "repetition range out of order",
+			pattern: "a{3,1}",
+			wantErr: true,
+		},
+		{
+			name:    "repetition count too large",
+			pattern: "a{1001}",
+			wantErr: true,
+		},
+		{
+			name:    "nested repetition counts within the size limit",
+			pattern: "(a{1000}){1000}",
+			wantErr: false,
+		},
+		{
+			name:    "nested repetition counts expanding too far",
+			pattern: "((a{1000}){1000}){1000}",
+			wantErr: true,
+		},
+		{
+			name:    "unknown letter escape",
+			pattern: "\\e",
+			wantErr: true,
+		},
+		{
+			name:    "quote escape",
+			pattern: "\\Qa.b\\E",
+			wantErr: true,
+		},
+		{
+			name:    "unknown escape inside brackets",
+			pattern: "[\\y]",
+			wantErr: true,
+		},
+		{
+			name:    "escaped punctuation",
+			pattern: "\\-\\_\\#",
+			wantErr: false,
+		},
+		{
+			name:    "text anchors",
+			pattern: "\\Afoo\\z",
+			wantErr: false,
+		},
+		{
+			name:    "star with nothing to repeat",
+			pattern: "*.go",
+			wantErr: true,
+		},
+		{
+			name:    "quantifier after alternation",
+			pattern: "a|*b",
+			wantErr: true,
+		},
+		{
+			name:    "quantifier at start of group",
+			pattern: "(+a)",
+			wantErr: true,
+		},
+		{
+			name:    "counted repetition with nothing to repeat",
+			pattern: "{2}a",
+			wantErr: true,
+		},
+		{
+			name:    "literal brace at start",
+			pattern: "{a}",
+			wantErr: false,
+		},
+		{
+			name:    "open-ended repetition",
+			pattern: "a{2,}",
+			wantErr: false,
+		},
+		{
+			name:    "nested repetition",
+			pattern: "a**",
+			wantErr: true,
+		},
+		{
+			name:    "repetition after lazy quantifier",
+			pattern: "a*?*",
+			wantErr: true,
+		},
+		{
+			name:    "nested counted repetition",
+			pattern: "a{2}{3}",
+			wantErr: true,
+		},
+		{
+			name:    "reversed class range",
+			pattern: "[z-a]",
+			wantErr: true,
+		},
+		{
+			name:    "range to escape class",
+			pattern: "[a-\\d]",
+			wantErr: true,
+		},
+		{
+			name:    "unknown POSIX class",
+			pattern: "[[:letter:]]",
+			wantErr: true,
+		},
+		{
+			name:    "unknown unicode class",
+			pattern: "\\p{Klingon}",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated unicode class",
+			pattern: "\\p{Greek",
+			wantErr: true,
+		},
+		{
+			name:    "invalid hex escape",
+			pattern: "\\xZZ",
+			wantErr: true,
+		},
+		{
+			name:    "incomplete hex escape",
+			pattern: "\\x4",
+			wantErr: true,
+		},
+		{
+			name:    "hex escape out of range",
+			pattern: "\\u{110000}",
+			wantErr: true,
+		},
+		{
+			name:    "trailing backslash",
+			pattern: "abc\\",
+			wantErr: true,
+		},
+		{
+			name:    "inline flags",
+			pattern: "(?i)a(?-i:b)(?ms)c",
+			wantErr: false,
+		},
+		{
+			name:    "unknown inline flag",
+			pattern: "(?x)a",
+			wantErr: true,
+		},
+		{
+			name:    "empty flag group",
+			pattern: "(?)a",
+			wantErr: true,
+		},
+		{
+			name:    "flag negation without flag",
+			pattern: "(?i-)a",
+			wantErr: true,
+		},
+		{
+			name:    "repeated flag negation",
+			pattern: "(?-i-m)a",
+			wantErr: true,
+		},
+		{
+			name:    "unterminated flag group",
+			pattern: "(?i",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.pattern)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Compile(%q) error = %v, wantErr %v", tt.pattern, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	tests := []struct {
+		name       string
+		pattern    string
+		wantOffset int
+		wantCaret  string
+	}{
+		{
+			name:       "unterminated class",
+			pattern:    "ab[cd",
+			wantOffset: 2,
+			wantCaret:  "\t  ^",
+		},
+		{
+			name:       "unterminated group",
+			pattern:    "x(ab",
+			wantOffset: 1,
+			wantCaret:  "\t ^",
+		},
+		{
+			name:       "unmatched closing paren",
+			pattern:    "ab)c",
+			wantOffset: 2,
+			wantCaret:  "\t  ^",
+		},
+		{
+			name:       "expression too large",
+			pattern:    "((a{1000}){1000}){1000}",
+			wantOffset: 0,
+			wantCaret:  "\t^",
+		},
+		{
+			name:       "caret counts characters not bytes",
+			pattern:    "é{3,1}",
+			wantOffset: 2,
+			wantCaret:  "\t ^",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Compile(tt.pattern)
+
+			var syntaxErr *SyntaxError
+			if !errors.As(err, &syntaxErr) {
+				t.Fatalf("Compile(%q) error = %v, want *SyntaxError", tt.pattern, err)
+			}
+
+			if syntaxErr.Offset != tt.wantOffset {
+				t.Errorf("Offset = %d, want %d", syntaxErr.Offset, tt.wantOffset)
+			}
+
+			want := "\t" + tt.pattern + "\n" + tt.wantCaret
+			if got := syntaxErr.Excerpt(); got != want {
+				t.Errorf("Excerpt() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestRegexpFindIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		pattern string
+		want    []int
+	}{
+		{
+			name:    "literal in the middle",
+			line:    "say hello",
+			pattern: "hello",
+			want:    []int{4, 9},
+		},
+		{
+			name:    "anchored start",
+			line:    "hello world",
+			pattern: "^hello",
+			want:    []int{0, 5},
+		},
+		{
+			name:    "anchored end",
+			line:    "hello world",
+			pattern: "world$",
+			want:    []int{6, 11},
+		},
+		{
+			name:    "alternation followed by literal",
+			line:    "hotdogs",
+			pattern: "(cat|dog)s",
+			want:    []int{3, 7},
+		},
+		{
+			name:    "greedy digits",
+			line:    "v12345",
+			pattern: "\\d+",
+			want:    []int{1, 6},
+		},
+		{
+			name:    "greedy dot gives back for suffix",
+			line:    "a.b.c",
+			pattern: "a.*\\.",
+			want:    []int{0, 4},
+		},
+		{
+			name:    "byte offsets after multibyte characters",
+			line:    "héllo",
+			pattern: "l+",
+			want:    []int{3, 5},
+		},
+		{
+			name:    "lazy star stops at first quote",
+			line:    `say "hi" and "bye"`,
+			pattern: `".*?"`,
+			want:    []int{4, 8},
+		},
+		{
+			name:    "greedy star runs to last quote",
+			line:    `say "hi" and "bye"`,
+			pattern: `".*"`,
+			want:    []int{4, 18},
+		},
+		{
+			name:    "lazy plus",
+			line:    "aaa",
+			pattern: "a+?",
+			want:    []int{0, 1},
+		},
+		{
+			name:    "lazy optional still matches when needed",
+			line:    "ab",
+			pattern: "a??b",
+			want:    []int{0, 2},
+		},
+		{
+			name:    "lazy counted repetition",
+			line:    "aaaa",
+			pattern: "a{2,4}?",
+			want:    []int{0, 2},
+		},
+		{
+			name:    "possessive star never gives back",
+			line:    "aaa",
+			pattern: "a*+a",
+			want:    nil,
+		},
+		{
+			name:    "possessive optional never gives back",
+			line:    "a",
+			pattern: "a?+a",
+			want:    nil,
+		},
+		{
+			name:    "possessive class run",
+			line:    `x "abc" y`,
+			pattern: `"[^"]*+"`,
+			want:    []int{2, 7},
+		},
+		{
+			name:    "possessive counted repetition",
+			line:    "aaaa",
+			pattern: "a{1,3}+a",
+			want:    []int{0, 4},
+		},
+		{
+			name:    "leftmost-first alternation",
+			line:    "abcd",
+			pattern: "(ab|abcd)",
+			want:    []int{0, 2},
+		},
+		{
+			name:    "greedy star backtracks",
+			line:    "xaaab",
+			pattern: "a*ab",
+			want:    []int{1, 5},
+		},
+		{
+			name:    "empty match at end of line",
+			line:    "",
+			pattern: "a*",
+			want:    []int{0, 0},
+		},
+		{
+			name:    "no match",
+			line:    "hello",
+			pattern: "xyz",
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re := MustCompile(tt.pattern)
+			got := re.FindIndex([]byte(tt.line))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindIndex() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegexpFindSubmatchIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		pattern string
+		want    []int
+	}{
+		{
+			name:    "single group",
+			line:    "key=value",
+			pattern: "(\\w+)=",
+			want:    []int{0, 4, 0, 3},
+		},
+		{
+			name:    "nested groups",
+			line:    "abc",
+			pattern: "(a(b)c)",
+			want:    []int{0, 3, 0, 3, 1, 2},
+		},
+		{
+			name:    "group inside lookahead",
+			line:    "xy",
+			pattern: "x(?=(y))",
+			want:    []int{0, 1, 1, 2},
+		},
+		{
+			name:    "group inside lookbehind",
+			line:    "ab",
+			pattern: "(?<=(a))b",
+			want:    []int{1, 2, 0, 1},
+		},
+		{
+			name:    "group inside negative lookahead stays unset",
+			line:    "xy",
+			pattern: "x(?!(z))",
+			want:    []int{0, 1, -1, -1},
+		},
+		{
+			name:    "quantified group keeps last iteration",
+			line:    "ababab",
+			pattern: "(ab)+",
+			want:    []int{0, 6, 4, 6},
+		},
+		{
+			name:    "group not taking part in the match",
+			line:    "b",
+			pattern: "(a)|b",
+			want:    []int{0, 1, -1, -1},
+		},
+		{
+			name:    "backreference",
+			line:    "say hello hello",
+			pattern: "(\\w+) \\1",
+			want:    []int{4, 15, 4, 9},
+		},
+		{
+			name:    "lookahead is zero-width",
+			line:    "main.go",
+			pattern: "(\\w+)(?=\\.go)",
+			want:    []int{0, 4, 0, 4},
+		},
+		{
+			name:    "lookbehind is zero-width",
+			line:    "cost $42",
+			pattern: "(?<=\\$)(\\d+)",
+			want:    []int{6, 8, 6, 8},
+		},
+		{
+			name:    "lazy group then greedy rest",
+			line:    "aaa",
+			pattern: "(a+?)(a*)",
+			want:    []int{0, 3, 0, 1, 1, 3},
+		},
+		{
+			name:    "possessive group keeps captures",
+			line:    "ababc",
+			pattern: "(ab)++c",
+			want:    []int{0, 5, 2, 4},
+		},
+		{
+			name:    "non-capturing group",
+			line:    "abab",
+			pattern: "(?:ab)+",
+			want:    []int{0, 4},
+		},
+		{
+			name:    "empty first iteration keeps its captures",
+			line:    ".",
+			pattern: "$()*",
+			want:    []int{1, 1, 1, 1},
+		},
+		{
+			name:    "empty iteration does not follow a non-empty one",
+			line:    "-",
+			pattern: "(-|)+",
+			want:    []int{0, 1, 0, 1},
+		},
+		{
+			name:    "empty first iteration ends the loop",
+			line:    ".",
+			pattern: "(|\\D)+",
+			want:    []int{0, 0, 0, 0},
+		},
+		{
+			name:    "lazy loop over a lazy nullable group",
+			line:    "..",
+			pattern: "(\\D*?)+?$",
+			want:    []int{0, 2, 0, 2},
+		},
+		{
+			name:    "no match",
+			line:    "xyz",
+			pattern: "(a)",
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re := MustCompile(tt.pattern)
+			got := re.FindSubmatchIndex([]byte(tt.line))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindSubmatchIndex() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegexpNamedGroups(t *testing.T) {
+	re := MustCompile("(?P<name>\\w+)\\.(?P<ext>\\w+)")
+
+	if got := re.NumSubexp(); got != 2 {
+		t.Errorf("NumSubexp() = %d, want 2", got)
+	}
+
+	wantNames := []string{"", "name", "ext"}
+	if got := re.SubexpNames(); !reflect.DeepEqual(got, wantNames) {
+		t.Errorf("SubexpNames() = %v, want %v", got, wantNames)
+	}
+
+	subs := re.FindSubmatch([]byte("match.go"))
+	if got := string(subs[re.SubexpIndex("ext")]); got != "go" {
+		t.Errorf("ext group = %q, want %q", got, "go")
+	}
+
+	if got := re.SubexpIndex("missing"); got != -1 {
+		t.Errorf("SubexpIndex(missing) = %d, want -1", got)
+	}
+}
+
+func TestCompileWithOptions(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		opts    CompileOptions
+		line    string
+		want    []int
+	}{
+		{
+			name:    "case-insensitive",
+			pattern: "go",
+			opts:    CompileOptions{CaseInsensitive: true},
+			line:    "MAIN.GO",
+			want:    []int{5, 7},
+		},
+		{
+			name:    "inline flag overrides option",
+			pattern: "(?-i)go",
+			opts:    CompileOptions{CaseInsensitive: true},
+			line:    "MAIN.GO",
+			want:    nil,
+		},
+		{
+			name:    "case-insensitive negated property class",
+			pattern: "\\P{Ll}",
+			opts:    CompileOptions{CaseInsensitive: true},
+			line:    "a",
+			want:    nil,
+		},
+		{
+			name:    "case-insensitive negated property class in brackets",
+			pattern: "[\\P{Ll}]",
+			opts:    CompileOptions{CaseInsensitive: true},
+			line:    "a",
+			want:    nil,
+		},
+		{
+			name:    "case-insensitive negated property class in brackets matches non-letters",
+			pattern: "[\\P{Ll}]",
+			opts:    CompileOptions{CaseInsensitive: true},
+			line:    "a1",
+			want:    []int{1, 2},
+		},
+		{
+			name:    "case-insensitive negated bracket of negated property class",
+			pattern: "[^\\P{Lu}]",
+			opts:    CompileOptions{CaseInsensitive: true},
+			line:    "a",
+			want:    []int{0, 1},
+		},
+		{
+			name:    "multiline",
+			pattern: "^b+$",
+			opts:    CompileOptions{Multiline: true},
+			line:    "a\nbb\nc",
+			want:    []int{2, 4},
+		},
+		{
+			name:    "dot-all",
+			pattern: "a.*c",
+			opts:    CompileOptions{DotAll: true},
+			line:    "a\nb\nc",
+			want:    []int{0, 5},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := CompileWithOptions(tt.pattern, tt.opts)
+			if err != nil {
+				t.Fatalf("CompileWithOptions(%q) error = %v", tt.pattern, err)
+			}
+			if got := re.FindIndex([]byte(tt.line)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindIndex() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegexpFindAll(t *testing.T) {
+	re := MustCompile("\\d")
+	got := re.FindAll([]byte("a1b2c3"), -1)
+	want := [][]byte{[]byte("1"), []byte("2"), []byte("3")}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FindAll() = %q, want %q", got, want)
+	}
+
+	got = re.FindAll([]byte("a1b2c3"), 2)
+	if len(got) != 2 {
+		t.Errorf("FindAll() with limit returned %d matches, want 2", len(got))
+	}
+}
+
+func TestRegexpFindAllIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		line    string
+		n       int
+		want    [][]int
+	}{
+		{
+			name:    "all matches",
+			pattern: "o+",
+			line:    "foo boo",
+			n:       -1,
+			want:    [][]int{{1, 3}, {5, 7}},
+		},
+		{
+			name:    "limited",
+			pattern: "o+",
+			line:    "foo boo",
+			n:       1,
+			want:    [][]int{{1, 3}},
+		},
+		{
+			name:    "no empty match right after a match",
+			pattern: "a*",
+			line:    "baaac",
+			n:       -1,
+			want:    [][]int{{0, 0}, {1, 4}, {5, 5}},
+		},
+		{
+			name:    "no match",
+			pattern: "x",
+			line:    "abc",
+			n:       -1,
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MustCompile(tt.pattern).FindAllIndex([]byte(tt.line), tt.n)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindAllIndex() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegexpReplaceAll(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		line     string
+		template string
+		want     string
+	}{
+		{
+			name:     "literal template",
+			pattern:  "\\d+",
+			line:     "a1b22c",
+			template: "#",
+			want:     "a#b#c",
+		},
+		{
+			name:     "numbered groups",
+			pattern:  "(\\w+)\\.(\\w+)",
+			line:     "main.go",
+			template: "$2.$1",
+			want:     "go.main",
+		},
+		{
+			name:     "braced group followed by digits",
+			pattern:  "(a)",
+			line:     "a",
+			template: "${1}0",
+			want:     "a0",
+		},
+		{
+			name:     "named group",
+			pattern:  "(?P<stem>\\w+)\\.go",
+			line:     "main.go util.go",
+			template: "${stem}_test.go",
+			want:     "main_test.go util_test.go",
+		},
+		{
+			name:     "escaped and lone dollars",
+			pattern:  "x",
+			line:     "x",
+			template: "$$ $ $a ${1",
+			want:     "$ $ $a ${1",
+		},
+		{
+			name:     "missing and unmatched groups expand to nothing",
+			pattern:  "(a)|(b)",
+			line:     "b",
+			template: "[$1][$2][$3][${nope}]",
+			want:     "[][b][][]",
+		},
+		{
+			name:     "empty matches",
+			pattern:  "a*",
+			line:     "baaac",
+			template: "-",
+			want:     "-b-c-",
+		},
+		{
+			name:     "no match",
+			pattern:  "x",
+			line:     "abc",
+			template: "-",
+			want:     "abc",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MustCompile(tt.pattern).ReplaceAll([]byte(tt.line), []byte(tt.template))
+			if string(got) != tt.want {
+				t.Errorf("ReplaceAll() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegexpSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		line    string
+		n       int
+		want    []string
+	}{
+		{
+			name:    "separator",
+			pattern: ",\\s*",
+			line:    "a, b,c",
+			n:       -1,
+			want:    []string{"a", "b", "c"},
+		},
+		{
+			name:    "trailing separator",
+			pattern: ",",
+			line:    "a,b,",
+			n:       -1,
+			want:    []string{"a", "b", ""},
+		},
+		{
+			name:    "limited",
+			pattern: ",",
+			line:    "a,b,c",
+			n:       2,
+			want:    []string{"a", "b,c"},
+		},
+		{
+			name:    "empty pattern splits characters",
+			pattern: "",
+			line:    "aé",
+			n:       -1,
+			want:    []string{"a", "é"},
+		},
+		{
+			name:    "no match",
+			pattern: ",",
+			line:    "abc",
+			n:       -1,
+			want:    []string{"abc"},
+		},
+		{
+			name:    "zero parts",
+			pattern: ",",
+			line:    "a,b",
+			n:       0,
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, part := range MustCompile(tt.pattern).Split([]byte(tt.line), tt.n) {
+				got = append(got, string(part))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Split() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegexpFindAllEmptyMatchesUTF8(t *testing.T) {
+	re := MustCompile("x*")
+	got := re.FindAll([]byte("é"), -1)
+	if len(got) != 2 {
+		t.Errorf("FindAll() returned %d matches, want 2", len(got))
+	}
+}
+
+func TestRegexpReuse(t *testing.T) {
+	re := MustCompile("^[^.]+\\.go$")
+	lines := map[string]bool{
+		"main.go":       true,
+		"main.go.bak":   false,
+		"README.md":     false,
+		"match_test.go": true,
+	}
+
+	for line, want := range lines {
+		if got := re.Match([]byte(line)); got != want {
+			t.Errorf("Match(%q) = %v, want %v", line, got, want)
+		}
+	}
+}
+
+func TestRegexpReuseConcurrent(t *testing.T) {
+	// Searches share pooled Pike VMs, so none may see threads or captures left over
+	// from another, whichever line it searched and however many groups it recorded.
+	re := MustCompile(`(\w+)@(\w+)\.com`)
+	tests := []struct {
+		line string
+		want []int
+	}{
+		{"mail bob@example.com now", []int{5, 20, 5, 8, 9, 16}},
+		{"no address here", nil},
+		{"a@b.com", []int{0, 7, 0, 1, 2, 3}},
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				tt := tests[j%len(tests)]
+				if got := re.FindSubmatchIndex([]byte(tt.line)); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("FindSubmatchIndex(%q) = %v, want %v", tt.line, got, tt.want)
+					return
+				}
+				var want []int
+				if tt.want != nil {
+					want = tt.want[:2]
+				}
+				if got := re.FindIndex([]byte(tt.line)); !reflect.DeepEqual(got, want) {
+					t.Errorf("FindIndex(%q) = %v, want %v", tt.line, got, want)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestRegexpMatchBudget(t *testing.T) {
+	// The backreference forces the backtracker, which tries every way of splitting the
+	// a's between the two alternatives before failing.
+	pathological := "^(a|a)*\\1b"
+	line := []byte(strings.Repeat("a", 40) + "cb")
+
+	re, err := CompileWithOptions(pathological, CompileOptions{MaxSteps: 10000})
+	if err != nil {
+		t.Fatalf("CompileWithOptions() error = %v", err)
+	}
+
+	matched, err := re.MatchContext(context.Background(), line)
+	if matched || !errors.Is(err, ErrMatchBudgetExceeded) {
+		t.Errorf("MatchContext() = %v, %v, want false, ErrMatchBudgetExceeded", matched, err)
+	}
+	if re.Match(line) {
+		t.Errorf("Match() = true, want false once the budget runs out")
+	}
+
+	loc, err := re.FindIndexContext(context.Background(), []byte("aab"))
+	if !reflect.DeepEqual(loc, []int{0, 3}) || err != nil {
+		t.Errorf("FindIndexContext(aab) = %v, %v, want [0 3], nil", loc, err)
+	}
+}
+
+func TestRegexpMatchContextCanceled(t *testing.T) {
+	re := MustCompile("^(a|a)*\\1b")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	matched, err := re.MatchContext(ctx, []byte(strings.Repeat("a", 40)+"cb"))
+	if matched || !errors.Is(err, ErrMatchBudgetExceeded) || !errors.Is(err, context.Canceled) {
+		t.Errorf("MatchContext() = %v, %v, want false and an error wrapping ErrMatchBudgetExceeded and context.Canceled", matched, err)
+	}
+
+	matched, err = re.MatchContext(ctx, []byte("b"))
+	if matched || err != nil {
+		t.Errorf("MatchContext(b) = %v, %v, want false, nil for a search that finishes quickly", matched, err)
+	}
+}
+
+func TestRegexpLookbehindTime(t *testing.T) {
+	// Every 'b' is a candidate, so the lookbehind is checked at every position, and
+	// scanning back to the start of the line each time would take quadratic time.
+	line := []byte(strings.Repeat("b", 1<<20) + "ab")
+
+	re := MustCompile("(?<=a)b")
+	start := time.Now()
+	if !re.Match(line) {
+		t.Errorf("Match() = false, want true")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Match() took %v on a %d byte line", elapsed, len(line))
+	}
+}
+
+func TestRegexpLinearTime(t *testing.T) {
+	// (a?){n}a{n} against a^n takes exponential time in a backtracking matcher.
+	n := 30
+	pattern := strings.Repeat("a?", n) + strings.Repeat("a", n)
+	line := []byte(strings.Repeat("a", n))
+
+	re := MustCompile(pattern)
+	if !re.Match(line) {
+		t.Errorf("Match(%q) = false, want true", line)
+	}
+}
@@ -0,0 +1,121 @@
+package matcher
+
+import (
+	"bufio"
+	"errors"
+	"io"
+)
+
+const (
+	scanBufferSize = 64 * 1024 // bytes read from the reader at a time
+	MaxLineLength  = 1 << 20   // longest line ScanReader matches in one piece
+)
+
+// LineMatch is a match found by ScanReader. Text and LineText point into the scanner's
+// buffer and are only valid until the callback returns.
+type LineMatch struct {
+	Line     int    // 1-based number of the line the match is on
+	Column   int    // 1-based byte offset of the match in its line
+	Offset   int64  // byte offset of the match from the start of the reader
+	Text     []byte // the matched text
+	LineText []byte // the line the match is on, without its line terminator
+}
+
+// ScanReader reads lines from r and calls fn with every match of the pattern, in order,
+// until fn returns false or the reader is exhausted. Lines end at "\n" or "\r\n", and the
+// terminator is not part of the line the pattern sees. The last line need not end in one.
+//
+// The reader is consumed through a single reusable buffer. A line longer than
+// MaxLineLength is matched in pieces of that length, so memory use stays bounded but a
+// match straddling two pieces is not found, and LineText only holds the piece.
+//
+// Parameters:
+// - r: The reader to scan.
+// - fn: The function called for each match; returning false stops the scan.
+//
+// Returns:
+// - error: The first error returned by the reader other than io.EOF, or nil.
+func (re *Regexp) ScanReader(r io.Reader, fn func(LineMatch) bool) error {
+	return re.scanReader(r, fn, scanBufferSize, MaxLineLength)
+}
+
+// scanReader is ScanReader with the buffer size and the longest line to match in one
+// piece as parameters.
+func (re *Regexp) scanReader(r io.Reader, fn func(LineMatch) bool, bufSize, maxLine int) error {
+	br := bufio.NewReaderSize(r, bufSize)
+
+	var line []byte         // the part of the current line not matched yet
+	lineNum, column := 1, 1 // line number and column of line[0]
+	var offset int64        // offset in the reader of line[0]
+
+	for {
+		frag, err := br.ReadSlice('\n')
+		if err != nil && !errors.Is(err, bufio.ErrBufferFull) && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		ended := err == nil
+		if ended {
+			frag = frag[:len(frag)-1]
+		}
+		line = append(line, frag...)
+
+		for len(line) > maxLine {
+			piece := maxLine
+			if piece > 1 && line[piece-1] == '\r' {
+				piece--
+			}
+			if !re.scanLine(line[:piece], lineNum, column, offset, fn) {
+				return nil
+			}
+			column += piece
+			offset += int64(piece)
+			line = append(line[:0], line[piece:]...)
+		}
+
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+
+		terminator := 0
+		if ended {
+			terminator = 1
+			if n := len(line); n > 0 && line[n-1] == '\r' {
+				line = line[:n-1]
+				terminator = 2
+			}
+		}
+
+		if len(line) > 0 || ended && column == 1 {
+			if !re.scanLine(line, lineNum, column, offset, fn) {
+				return nil
+			}
+		}
+		if err != nil {
+			return nil
+		}
+
+		offset += int64(len(line) + terminator)
+		line = line[:0]
+		lineNum++
+		column = 1
+	}
+}
+
+// scanLine calls fn with every match in a line, or a piece of one, that starts at the
+// given column and reader offset. It reports whether fn asked for more matches.
+func (re *Regexp) scanLine(line []byte, lineNum, column int, offset int64, fn func(LineMatch) bool) bool {
+	for _, loc := range re.FindAllIndex(line, -1) {
+		m := LineMatch{
+			Line:     lineNum,
+			Column:   column + loc[0],
+			Offset:   offset + int64(loc[0]),
+			Text:     line[loc[0]:loc[1]],
+			LineText: line,
+		}
+		if !fn(m) {
+			return false
+		}
+	}
+	return true
+}
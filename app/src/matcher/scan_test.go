@@ -0,0 +1,127 @@
+package matcher
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// scanned is a LineMatch with its slices copied out of the scanner's buffer.
+type scanned struct {
+	Line, Column int
+	Offset       int64
+	Text, Source string
+}
+
+func scanAll(t *testing.T, re *Regexp, r io.Reader, bufSize, maxLine int) []scanned {
+	t.Helper()
+
+	var got []scanned
+	err := re.scanReader(r, func(m LineMatch) bool {
+		got = append(got, scanned{m.Line, m.Column, m.Offset, string(m.Text), string(m.LineText)})
+		return true
+	}, bufSize, maxLine)
+	if err != nil {
+		t.Fatalf("ScanReader() error = %v", err)
+	}
+	return got
+}
+
+func TestRegexpScanReader(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		input   string
+		want    []scanned
+	}{
+		{
+			name:    "matches on several lines",
+			pattern: "go+",
+			input:   "go\nstop\ngooo go\n",
+			want: []scanned{
+				{1, 1, 0, "go", "go"},
+				{3, 1, 8, "gooo", "gooo go"},
+				{3, 6, 13, "go", "gooo go"},
+			},
+		},
+		{
+			name:    "crlf line endings",
+			pattern: "\\w+$",
+			input:   "one two\r\nthree\r\n",
+			want: []scanned{
+				{1, 5, 4, "two", "one two"},
+				{2, 1, 9, "three", "three"},
+			},
+		},
+		{
+			name:    "last line without terminator",
+			pattern: "end",
+			input:   "a\nthe end",
+			want:    []scanned{{2, 5, 6, "end", "the end"}},
+		},
+		{
+			name:    "empty lines are matched",
+			pattern: "^$",
+			input:   "a\n\nb\n",
+			want:    []scanned{{2, 1, 2, "", ""}},
+		},
+		{
+			name:    "empty input",
+			pattern: "^",
+			input:   "",
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re := MustCompile(tt.pattern)
+			got := scanAll(t, re, strings.NewReader(tt.input), scanBufferSize, MaxLineLength)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ScanReader() = %+v, want %+v", got, tt.want)
+			}
+
+			small := scanAll(t, re, iotest.OneByteReader(strings.NewReader(tt.input)), 16, MaxLineLength)
+			if !reflect.DeepEqual(small, tt.want) {
+				t.Errorf("ScanReader() one byte at a time = %+v, want %+v", small, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegexpScanReaderLongLine(t *testing.T) {
+	input := strings.Repeat("x", 40) + "needle" + strings.Repeat("x", 40) + "\r\nneedle\n"
+	got := scanAll(t, MustCompile("needle"), strings.NewReader(input), 16, 32)
+
+	want := []scanned{
+		{1, 41, 40, "needle", input[32:64]},
+		{2, 1, 88, "needle", "needle"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ScanReader() = %+v, want %+v", got, want)
+	}
+}
+
+func TestRegexpScanReaderStop(t *testing.T) {
+	calls := 0
+	err := MustCompile("a").ScanReader(strings.NewReader("a\na\na\n"), func(LineMatch) bool {
+		calls++
+		return calls < 2
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("ScanReader() = %v after %d calls, want nil after 2", err, calls)
+	}
+}
+
+func TestRegexpScanReaderError(t *testing.T) {
+	readErr := errors.New("disk on fire")
+	r := io.MultiReader(strings.NewReader("a\n"), iotest.ErrReader(readErr))
+
+	err := MustCompile("a").ScanReader(r, func(LineMatch) bool { return true })
+	if !errors.Is(err, readErr) {
+		t.Errorf("ScanReader() error = %v, want %v", err, readErr)
+	}
+}
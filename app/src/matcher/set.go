@@ -0,0 +1,283 @@
+package matcher
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Set is a list of compiled patterns matched against a line together. A Set is safe
+// for concurrent use.
+//
+// The literals every match of a pattern must contain are looked for in one pass over
+// the line with an Aho-Corasick automaton, and only the patterns whose literals turned
+// up are run. A pattern that is nothing but a literal string matches as soon as its
+// string is found, without being run at all.
+type Set struct {
+	regexps    []*Regexp
+	ac         *ahoCorasick // nil if no pattern has required literals
+	exact      []bool       // pattern i matches whenever the automaton finds one of its literals
+	unfiltered []int        // patterns without required literals, which are run on every line
+}
+
+// CompileSet compiles a list of patterns into a Set. The id of each pattern is its
+// index in the list.
+//
+// Parameters:
+// - patterns: The pattern strings to be compiled.
+//
+// Returns:
+// - *Set: The compiled patterns.
+// - error: An error naming the first malformed pattern and wrapping its *SyntaxError.
+func CompileSet(patterns []string) (*Set, error) {
+	return CompileSetWithOptions(patterns, CompileOptions{})
+}
+
+// CompileSetWithOptions is like CompileSet but compiles every pattern with the given
+// options.
+func CompileSetWithOptions(patterns []string, opts CompileOptions) (*Set, error) {
+	s := &Set{
+		regexps: make([]*Regexp, len(patterns)),
+		exact:   make([]bool, len(patterns)),
+	}
+
+	var roots []*Node
+	fold := false
+	for i, pattern := range patterns {
+		re, err := CompileWithOptions(pattern, opts)
+		if err != nil {
+			return nil, fmt.Errorf("pattern %d: %w", i, err)
+		}
+		s.regexps[i] = re
+
+		root, folded := unfoldASCII(re.root)
+		roots = append(roots, root)
+		fold = fold || folded
+	}
+
+	var keywords []string
+	var ids []int
+	for i, root := range roots {
+		if lit, ok := pureLiteral(s.regexps[i].root, fold); ok {
+			keywords = append(keywords, lit)
+			ids = append(ids, i)
+			s.exact[i] = true
+			continue
+		}
+
+		required := literals(root).required
+		if required == nil {
+			s.unfiltered = append(s.unfiltered, i)
+			continue
+		}
+		for _, lit := range required {
+			keywords = append(keywords, lit)
+			ids = append(ids, i)
+		}
+	}
+
+	if len(keywords) > 0 {
+		s.ac = newAhoCorasick(keywords, ids, fold)
+	}
+	return s, nil
+}
+
+// MustCompileSet is like CompileSet but panics if a pattern cannot be parsed.
+func MustCompileSet(patterns []string) *Set {
+	s, err := CompileSet(patterns)
+	if err != nil {
+		panic("matcher: CompileSet: " + err.Error())
+	}
+	return s
+}
+
+// Len returns the number of patterns in the set.
+func (s *Set) Len() int {
+	return len(s.regexps)
+}
+
+// Regexp returns the compiled pattern with the given id.
+func (s *Set) Regexp(id int) *Regexp {
+	return s.regexps[id]
+}
+
+// Patterns returns the source patterns of the set, indexed by id.
+func (s *Set) Patterns() []string {
+	patterns := make([]string, len(s.regexps))
+	for i, re := range s.regexps {
+		patterns[i] = re.expr
+	}
+	return patterns
+}
+
+// Match reports whether the line contains a match of any pattern in the set.
+func (s *Set) Match(line []byte) bool {
+	ids, _ := s.matches(nil, line, true)
+	return len(ids) > 0
+}
+
+// MatchContext is like Match but gives up once ctx is done, and stops running a pattern
+// once it has taken more than its MaxSteps steps. If no pattern matched and one gave
+// up, the error wraps ErrMatchBudgetExceeded.
+func (s *Set) MatchContext(ctx context.Context, line []byte) (bool, error) {
+	ids, err := s.matches(ctx, line, true)
+	if len(ids) > 0 {
+		return true, nil
+	}
+	return false, err
+}
+
+// Matches returns the ids of the patterns that match the line, in increasing order,
+// or nil if none does.
+func (s *Set) Matches(line []byte) []int {
+	ids, _ := s.matches(nil, line, false)
+	return ids
+}
+
+// MatchesContext is like Matches but gives up once ctx is done, and stops running a
+// pattern once it has taken more than its MaxSteps steps. The error wraps
+// ErrMatchBudgetExceeded if any pattern gave up; the ids of the patterns that did
+// match are returned along with it.
+func (s *Set) MatchesContext(ctx context.Context, line []byte) ([]int, error) {
+	return s.matches(ctx, line, false)
+}
+
+// matches returns the ids of the matching patterns, stopping at the first one if first
+// is set, along with the first error a pattern's budget reported.
+func (s *Set) matches(ctx context.Context, line []byte, first bool) ([]int, error) {
+	candidates := s.candidates(line)
+
+	var ids []int
+	var firstErr error
+	for id, re := range s.regexps {
+		switch candidates[id] {
+		case candidateNone:
+			continue
+		case candidateMatch:
+			ids = append(ids, id)
+		default:
+			matched, err := re.match(line, re.budget(ctx))
+			if err != nil && firstErr == nil {
+				firstErr = err
+			}
+			if !matched {
+				continue
+			}
+			ids = append(ids, id)
+		}
+
+		if first {
+			break
+		}
+	}
+	return ids, firstErr
+}
+
+// Outcomes of the literal pass for a single pattern.
+const (
+	candidateNone  = iota // the pattern cannot match
+	candidateRun          // the pattern has to be run to tell
+	candidateMatch        // the pattern matches
+)
+
+// candidates runs the literal pass over the line and returns the outcome for every
+// pattern. Lines that are not ASCII skip it when the automaton folds case, since a
+// letter like 'k' may then match a character such as the Kelvin sign.
+func (s *Set) candidates(line []byte) []uint8 {
+	candidates := make([]uint8, len(s.regexps))
+
+	if s.ac == nil || s.ac.fold && !isASCII(line) {
+		for i := range candidates {
+			candidates[i] = candidateRun
+		}
+		return candidates
+	}
+
+	for _, id := range s.unfiltered {
+		candidates[id] = candidateRun
+	}
+	s.ac.scan(line, func(id int) {
+		if s.exact[id] {
+			candidates[id] = candidateMatch
+		} else {
+			candidates[id] = candidateRun
+		}
+	})
+	return candidates
+}
+
+// unfoldASCII returns a copy of the tree with case folding turned off for ASCII
+// literals, and whether there were any. The literals found in the copy are then
+// required in any match of the original once ASCII letters are lowered on both sides.
+func unfoldASCII(n *Node) (*Node, bool) {
+	if n.Kind == NodeLiteral && n.Fold && n.Char < utf8.RuneSelf {
+		unfolded := *n
+		unfolded.Fold = false
+		return &unfolded, true
+	}
+	if len(n.Children) == 0 {
+		return n, false
+	}
+
+	copied := *n
+	copied.Children = make([]*Node, len(n.Children))
+	folded := false
+	for i, child := range n.Children {
+		var f bool
+		copied.Children[i], f = unfoldASCII(child)
+		folded = folded || f
+	}
+	return &copied, folded
+}
+
+// pureLiteral returns the string a node matches if it is made of nothing but literal
+// characters and capture groups, compared the way the automaton compares them: with
+// ASCII letters lowered when fold is set. It returns false for any other node, and
+// for the empty string.
+func pureLiteral(n *Node, fold bool) (string, bool) {
+	var lit []byte
+	var walk func(n *Node) bool
+	walk = func(n *Node) bool {
+		switch n.Kind {
+		case NodeLiteral:
+			if n.Char == utf8.RuneError {
+				return false
+			}
+			if n.Fold && n.Char >= utf8.RuneSelf || fold && !n.Fold && canFold(n.Char) && n.Char < utf8.RuneSelf {
+				return false
+			}
+			char := n.Char
+			if fold && 'A' <= char && char <= 'Z' {
+				char += 'a' - 'A'
+			}
+			lit = utf8.AppendRune(lit, char)
+			return true
+		case NodeCapture:
+			return walk(n.Children[0])
+		case NodeConcat:
+			for _, child := range n.Children {
+				if !walk(child) {
+					return false
+				}
+			}
+			return true
+		default:
+			return false
+		}
+	}
+
+	if !walk(n) || len(lit) == 0 {
+		return "", false
+	}
+	return string(lit), true
+}
+
+// isASCII reports whether every byte of the line is ASCII.
+func isASCII(line []byte) bool {
+	for _, b := range line {
+		if b >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}